@@ -0,0 +1,123 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"hash/fnv"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/petrostrak/gomdb/internal/data"
+)
+
+// movieVersionTag returns the entity tag naming the current version of a movie, which
+// conditional writes are checked against.
+func movieVersionTag(movie *data.Movie) string {
+	return fmt.Sprintf(`"%s-%d"`, movie.ID, movie.Version)
+}
+
+// movieETag returns the entity tag of a representation of a movie. It extends the
+// version tag of the movie with a digest of the representation, so that it also changes
+// with the resources embedded in the movie, such as its rating, credits and collections,
+// none of which bump its version, and differs between the languages and fieldsets the
+// movie is requested in.
+func movieETag(movie *data.Movie, representation any) (string, error) {
+	js, err := json.Marshal(representation)
+	if err != nil {
+		return "", err
+	}
+
+	h := fnv.New64a()
+	h.Write(js)
+
+	return fmt.Sprintf(`"%s-%d-%016x"`, movie.ID, movie.Version, h.Sum64()), nil
+}
+
+// setMovieValidators adds the ETag and Last-Modified headers describing the given
+// representation of the current version of a movie. Last-Modified only follows changes
+// to the movie itself, so clients are expected to revalidate with the ETag.
+func setMovieValidators(headers http.Header, movie *data.Movie, representation any) error {
+	etag, err := movieETag(movie, representation)
+	if err != nil {
+		return err
+	}
+
+	headers.Set("ETag", etag)
+	headers.Set("Last-Modified", movie.UpdatedAt.UTC().Format(http.TimeFormat))
+
+	return nil
+}
+
+// etagMatches reports whether the entity tag appears in the comma-separated list of an
+// If-Match or If-None-Match header, where "*" matches any tag. Weak comparison ignores
+// the W/ prefix, as required for If-None-Match; If-Match uses strong comparison.
+func etagMatches(list, etag string, weak bool) bool {
+	for _, candidate := range strings.Split(list, ",") {
+		candidate = strings.TrimSpace(candidate)
+
+		if candidate == "*" {
+			return true
+		}
+
+		if weak {
+			candidate = strings.TrimPrefix(candidate, "W/")
+		}
+
+		if candidate == etag {
+			return true
+		}
+	}
+
+	return false
+}
+
+// notModified reports whether a GET request for a resource with the given validators
+// can be answered with 304 Not Modified. If-Modified-Since is only consulted when the
+// request has no If-None-Match header.
+func notModified(r *http.Request, etag string, lastModified time.Time) bool {
+	if list := strings.Join(r.Header.Values("If-None-Match"), ","); list != "" {
+		return etagMatches(list, etag, true)
+	}
+
+	since, err := http.ParseTime(r.Header.Get("If-Modified-Since"))
+	if err != nil {
+		return false
+	}
+
+	return !lastModified.Truncate(time.Second).After(since)
+}
+
+// ifMatchFailed reports whether the request carries an If-Match header which doesn't
+// match the given entity tag, meaning the client's copy of the resource is out of date.
+// The tag is the version tag of the resource, which the entity tag of any of its
+// representations matches too, as they all describe the same version.
+func ifMatchFailed(r *http.Request, etag string) bool {
+	list := strings.Join(r.Header.Values("If-Match"), ",")
+	if list == "" {
+		return false
+	}
+
+	if etagMatches(list, etag, false) {
+		return false
+	}
+
+	prefix := strings.TrimSuffix(etag, `"`) + "-"
+
+	for _, candidate := range strings.Split(list, ",") {
+		if strings.HasPrefix(strings.TrimSpace(candidate), prefix) {
+			return false
+		}
+	}
+
+	return true
+}
+
+// writeNotModified sends a 304 Not Modified response with the given headers.
+func writeNotModified(w http.ResponseWriter, headers http.Header) {
+	for key, value := range headers {
+		w.Header()[key] = value
+	}
+
+	w.WriteHeader(http.StatusNotModified)
+}
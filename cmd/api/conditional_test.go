@@ -0,0 +1,101 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/petrostrak/gomdb/internal/data"
+)
+
+func Test_notModified(t *testing.T) {
+	etag := `"121f03cd-ce8c-447d-8747-fb8cb7aa3a52-2"`
+	lastModified := time.Date(2024, 1, 2, 15, 4, 5, 0, time.UTC)
+
+	tests := []struct {
+		header   string
+		value    string
+		expected bool
+	}{
+		{"If-None-Match", etag, true},
+		{"If-None-Match", `"other", W/` + etag, true},
+		{"If-None-Match", "*", true},
+		{"If-None-Match", `"121f03cd-ce8c-447d-8747-fb8cb7aa3a52-1"`, false},
+		{"If-Modified-Since", lastModified.Format(http.TimeFormat), true},
+		{"If-Modified-Since", lastModified.Add(-time.Second).Format(http.TimeFormat), false},
+		{"If-Modified-Since", "yesterday", false},
+	}
+
+	for _, tt := range tests {
+		r := httptest.NewRequest(http.MethodGet, "/", nil)
+		r.Header.Set(tt.header, tt.value)
+
+		if got := notModified(r, etag, lastModified); got != tt.expected {
+			t.Errorf("%s: %s: expected %t but got %t", tt.header, tt.value, tt.expected, got)
+		}
+	}
+}
+
+func Test_ifMatchFailed(t *testing.T) {
+	etag := `"121f03cd-ce8c-447d-8747-fb8cb7aa3a52-2"`
+
+	tests := []struct {
+		value    string
+		expected bool
+	}{
+		{"", false},
+		{etag, false},
+		{"*", false},
+		{"W/" + etag, true},
+		{`"121f03cd-ce8c-447d-8747-fb8cb7aa3a52-1"`, true},
+		{`"121f03cd-ce8c-447d-8747-fb8cb7aa3a52-2-9b1c3f0a"`, false},
+		{`"121f03cd-ce8c-447d-8747-fb8cb7aa3a52-1-9b1c3f0a"`, true},
+		{`"121f03cd-ce8c-447d-8747-fb8cb7aa3a52-20"`, true},
+	}
+
+	for _, tt := range tests {
+		r := httptest.NewRequest(http.MethodPatch, "/", nil)
+		if tt.value != "" {
+			r.Header.Set("If-Match", tt.value)
+		}
+
+		if got := ifMatchFailed(r, etag); got != tt.expected {
+			t.Errorf("%q: expected %t but got %t", tt.value, tt.expected, got)
+		}
+	}
+}
+
+func Test_movieETag(t *testing.T) {
+	movie := &data.Movie{ID: uuid.MustParse("121f03cd-ce8c-447d-8747-fb8cb7aa3a52"), Version: 2, Title: "Casablanca"}
+
+	etag, err := movieETag(movie, movie)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !strings.HasPrefix(etag, strings.TrimSuffix(movieVersionTag(movie), `"`)+"-") {
+		t.Errorf("expected %s to extend the version tag %s", etag, movieVersionTag(movie))
+	}
+
+	r := httptest.NewRequest(http.MethodPatch, "/", nil)
+	r.Header.Set("If-Match", etag)
+
+	if ifMatchFailed(r, movieVersionTag(movie)) {
+		t.Errorf("expected %s to match the version tag %s", etag, movieVersionTag(movie))
+	}
+
+	// A change to the representation alone, such as a new rating, changes the tag.
+	movie.RatingCount = 1
+
+	rated, err := movieETag(movie, movie)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if rated == etag {
+		t.Errorf("expected the tag to change with the representation but got %s twice", etag)
+	}
+}
@@ -0,0 +1,192 @@
+package main
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/petrostrak/gomdb/internal/data"
+	"github.com/petrostrak/gomdb/internal/validator"
+)
+
+const (
+	exportFormatNDJSON = "ndjson"
+	exportFormatCSV    = "csv"
+)
+
+// exportFlushInterval is the number of movies written between flushes of the response.
+const exportFlushInterval = 100
+
+// movieWriter writes movies to an export in a specific format.
+type movieWriter interface {
+	// Begin writes anything that must come before the first movie.
+	Begin() error
+	Write(movie *data.Movie) error
+	Flush() error
+}
+
+// csvMovieWriter writes movies as CSV rows below a header row. Genres are joined with
+// commas within their field, so the output can be imported again.
+type csvMovieWriter struct {
+	w *csv.Writer
+}
+
+func (c *csvMovieWriter) Begin() error {
+	return c.w.Write([]string{"id", "title", "year", "runtime", "genres", "status", "average_rating", "rating_count", "version"})
+}
+
+func (c *csvMovieWriter) Write(movie *data.Movie) error {
+	return c.w.Write([]string{
+		movie.ID.String(),
+		movie.Title,
+		strconv.FormatInt(int64(movie.Year), 10),
+		strconv.FormatInt(int64(movie.Runtime), 10),
+		strings.Join(movie.Genres, ","),
+		movie.Status,
+		strconv.FormatFloat(movie.AverageRating, 'f', -1, 64),
+		strconv.FormatInt(int64(movie.RatingCount), 10),
+		strconv.FormatInt(int64(movie.Version), 10),
+	})
+}
+
+func (c *csvMovieWriter) Flush() error {
+	c.w.Flush()
+	return c.w.Error()
+}
+
+// ndjsonMovieWriter writes every movie as a JSON object on its own line.
+type ndjsonMovieWriter struct {
+	enc *json.Encoder
+}
+
+func (n *ndjsonMovieWriter) Begin() error {
+	return nil
+}
+
+func (n *ndjsonMovieWriter) Write(movie *data.Movie) error {
+	return n.enc.Encode(movie)
+}
+
+func (n *ndjsonMovieWriter) Flush() error {
+	return nil
+}
+
+// exportMoviesHandler streams every movie matching the same filters as
+// listMoviesHandler, without pagination, as either NDJSON or CSV. The response is
+// flushed as it is written, so the client starts receiving movies straight away.
+func (app *application) exportMoviesHandler(w http.ResponseWriter, r *http.Request) {
+	var input struct {
+		data.MovieFilters
+		data.Filters
+		Format string
+	}
+
+	v := validator.New()
+
+	qs := r.URL.Query()
+
+	input.MovieFilters = app.readMovieFilters(qs, v)
+	input.Filters.Sort = app.readString(qs, "sort", "id")
+	input.Filters.SortSafelist = movieSortSafelist
+	input.Format = app.readString(qs, "format", exportFormatNDJSON)
+
+	v.Check(validator.In(input.Filters.Sort, input.Filters.SortSafelist...), "sort", "invalid sort value")
+	data.ValidateMovieSort(v, input.MovieFilters, input.Filters)
+	v.Check(validator.In(input.Format, exportFormatNDJSON, exportFormatCSV), "format", "must be either ndjson or csv")
+
+	if !v.Valid() {
+		app.failedValidationResponse(w, r, v.Errors)
+		return
+	}
+
+	var (
+		writer      movieWriter
+		contentType string
+	)
+
+	switch input.Format {
+	case exportFormatCSV:
+		writer = &csvMovieWriter{w: csv.NewWriter(w)}
+		contentType = "text/csv"
+	default:
+		writer = &ndjsonMovieWriter{enc: json.NewEncoder(w)}
+		contentType = "application/x-ndjson"
+	}
+
+	rc := http.NewResponseController(w)
+
+	// An export can take far longer than the server's write timeout allows for a
+	// regular response.
+	err := rc.SetWriteDeadline(time.Time{})
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	// The response is only started once the first movie has been read, so that a
+	// failing query can still be reported with a proper error response.
+	started := false
+	written := 0
+
+	begin := func() error {
+		w.Header().Set("Content-Type", contentType)
+		w.Header().Set("Content-Disposition", "attachment; filename=movies."+input.Format)
+		w.WriteHeader(http.StatusOK)
+		started = true
+
+		return writer.Begin()
+	}
+
+	err = app.models.Movies.Export(input.MovieFilters, input.Filters, func(movie *data.Movie) error {
+		if !started {
+			err := begin()
+			if err != nil {
+				return err
+			}
+		}
+
+		err := writer.Write(movie)
+		if err != nil {
+			return err
+		}
+
+		written++
+
+		if written%exportFlushInterval == 0 {
+			err = writer.Flush()
+			if err != nil {
+				return err
+			}
+			return rc.Flush()
+		}
+
+		return nil
+	})
+	if err != nil {
+		if !started {
+			app.serverErrorResponse(w, r, err)
+			return
+		}
+
+		// The status line has already gone out, so all that's left is to log the error
+		// and cut the export short.
+		app.logError(r, err)
+		return
+	}
+
+	if !started {
+		err = begin()
+		if err != nil {
+			app.logError(r, err)
+			return
+		}
+	}
+
+	err = writer.Flush()
+	if err != nil {
+		app.logError(r, err)
+	}
+}
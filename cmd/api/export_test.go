@@ -0,0 +1,52 @@
+package main
+
+import (
+	"bytes"
+	"encoding/csv"
+	"slices"
+	"testing"
+
+	"github.com/google/uuid"
+	"github.com/petrostrak/gomdb/internal/data"
+)
+
+func Test_csvMovieWriter(t *testing.T) {
+	movie := &data.Movie{
+		ID:      uuid.New(),
+		Title:   "Alien",
+		Year:    1979,
+		Runtime: 117,
+		Genres:  []string{"horror", "sci-fi"},
+		Status:  data.MovieStatusCancelled,
+		Version: 1,
+	}
+
+	var buf bytes.Buffer
+
+	writer := &csvMovieWriter{w: csv.NewWriter(&buf)}
+
+	for _, err := range []error{writer.Begin(), writer.Write(movie), writer.Flush()} {
+		if err != nil {
+			t.Fatalf("unexpected error: %s", err)
+		}
+	}
+
+	// An export must be readable by the importer.
+	reader, err := newMovieReader("text/csv", &buf)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	_, got, errs, err := reader.Next()
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	if len(errs) != 0 {
+		t.Fatalf("unexpected errors: %v", errs)
+	}
+
+	if got.Title != movie.Title || got.Year != movie.Year || got.Runtime != movie.Runtime || !slices.Equal(got.Genres, movie.Genres) || got.Status != movie.Status {
+		t.Errorf("expected %+v but got %+v", movie, got)
+	}
+}
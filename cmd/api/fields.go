@@ -0,0 +1,174 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"slices"
+
+	"github.com/google/uuid"
+	"github.com/petrostrak/gomdb/internal/data"
+	"github.com/petrostrak/gomdb/internal/validator"
+)
+
+// movieFields holds the names of the fields which can be requested with ?fields=.
+var movieFields = []string{"id", "title", "year", "runtime", "genres", "status", "next_release", "original_title", "external_ids", "images", "collections", "average_rating", "rating_count", "version", "headline"}
+
+// movieIncludes holds the names of the related resources which can be embedded in a
+// movie with ?include=.
+var movieIncludes = []string{"credits", "reviews", "releases", "certifications"}
+
+// embeddedReviewsLimit is the number of reviews embedded in each movie, latest first.
+const embeddedReviewsLimit = 5
+
+// readMovieFieldset reads the fields and include parameters, recording an error for
+// every name that isn't recognised.
+func (app *application) readMovieFieldset(qs url.Values, v *validator.Validator) (fields, include []string) {
+	fields = app.readCSV(qs, "fields", []string{})
+	for _, field := range fields {
+		v.Check(validator.In(field, movieFields...), "fields", fmt.Sprintf("unknown field %q", field))
+	}
+
+	include = app.readCSV(qs, "include", []string{})
+	for _, resource := range include {
+		v.Check(validator.In(resource, movieIncludes...), "include", fmt.Sprintf("unknown resource %q", resource))
+	}
+
+	return fields, include
+}
+
+// embedMovieResources loads the images, the collections and the included resources of
+// every movie, with a single query per resource rather than one per movie.
+func (app *application) embedMovieResources(movies []*data.Movie, include []string) error {
+	if len(movies) == 0 {
+		return nil
+	}
+
+	ids := make([]uuid.UUID, len(movies))
+	for i, movie := range movies {
+		ids[i] = movie.ID
+	}
+
+	images, err := app.models.Images.GetAllForMovies(ids)
+	if err != nil {
+		return err
+	}
+
+	collections, err := app.models.Collections.GetAllForMovies(ids)
+	if err != nil {
+		return err
+	}
+
+	for _, movie := range movies {
+		movie.Images = images[movie.ID]
+		app.setImageURLs(movie.Images)
+		movie.Collections = collections[movie.ID]
+	}
+
+	if validator.In("credits", include...) {
+		credits, err := app.models.Credits.GetAllForMovies(ids)
+		if err != nil {
+			return err
+		}
+
+		for _, movie := range movies {
+			movie.Credits = credits[movie.ID]
+			if movie.Credits == nil {
+				movie.Credits = []*data.Credit{}
+			}
+		}
+	}
+
+	if validator.In("reviews", include...) {
+		reviews, err := app.models.Reviews.GetLatestForMovies(ids, embeddedReviewsLimit)
+		if err != nil {
+			return err
+		}
+
+		for _, movie := range movies {
+			movie.Reviews = reviews[movie.ID]
+			if movie.Reviews == nil {
+				movie.Reviews = []*data.Review{}
+			}
+		}
+	}
+
+	if validator.In("releases", include...) {
+		releases, err := app.models.Releases.GetAllForMovies(ids)
+		if err != nil {
+			return err
+		}
+
+		for _, movie := range movies {
+			movie.Releases = releases[movie.ID]
+			if movie.Releases == nil {
+				movie.Releases = []*data.Release{}
+			}
+		}
+	}
+
+	if validator.In("certifications", include...) {
+		certifications, err := app.models.Certifications.GetAllForMovies(ids)
+		if err != nil {
+			return err
+		}
+
+		for _, movie := range movies {
+			movie.Certifications = certifications[movie.ID]
+			if movie.Certifications == nil {
+				movie.Certifications = []*data.Certification{}
+			}
+		}
+	}
+
+	return nil
+}
+
+// trimFields returns the JSON object for v reduced to the given fields. When no fields
+// are given, v is returned unchanged.
+func trimFields(v any, fields []string) (any, error) {
+	if len(fields) == 0 {
+		return v, nil
+	}
+
+	js, err := json.Marshal(v)
+	if err != nil {
+		return nil, err
+	}
+
+	var object map[string]json.RawMessage
+
+	err = json.Unmarshal(js, &object)
+	if err != nil {
+		return nil, err
+	}
+
+	for key := range object {
+		if !validator.In(key, fields...) {
+			delete(object, key)
+		}
+	}
+
+	return object, nil
+}
+
+// trimMovies reduces every movie to the requested fields, keeping any included
+// resources.
+func trimMovies(movies []*data.Movie, fields, include []string) ([]any, error) {
+	if len(fields) > 0 {
+		fields = append(slices.Clone(fields), include...)
+	}
+
+	trimmed := make([]any, len(movies))
+
+	for i, movie := range movies {
+		var err error
+
+		trimmed[i], err = trimFields(movie, fields)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return trimmed, nil
+}
@@ -0,0 +1,53 @@
+package main
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/google/uuid"
+	"github.com/petrostrak/gomdb/internal/data"
+)
+
+func Test_trimMovies(t *testing.T) {
+	movie := &data.Movie{
+		ID:      uuid.New(),
+		Title:   "Alien",
+		Year:    1979,
+		Runtime: 117,
+		Genres:  []string{"horror"},
+		Version: 1,
+		Credits: []*data.Credit{{ID: 1, Name: "Ridley Scott", Role: data.RoleDirector}},
+	}
+
+	tests := []struct {
+		fields   []string
+		include  []string
+		expected []string
+	}{
+		{[]string{"id", "title"}, nil, []string{"id", "title"}},
+		{[]string{"title"}, []string{"credits"}, []string{"title", "credits"}},
+		{nil, []string{"credits"}, []string{"id", "title", "original_title", "year", "runtime", "genres", "version", "credits"}},
+	}
+
+	for _, tt := range tests {
+		trimmed, err := trimMovies([]*data.Movie{movie}, tt.fields, tt.include)
+		if err != nil {
+			t.Fatalf("unexpected error: %s", err)
+		}
+
+		js, _ := json.Marshal(trimmed[0])
+
+		var object map[string]any
+		_ = json.Unmarshal(js, &object)
+
+		if len(object) != len(tt.expected) {
+			t.Errorf("expected fields %v but got %s", tt.expected, js)
+		}
+
+		for _, field := range tt.expected {
+			if _, ok := object[field]; !ok {
+				t.Errorf("expected field %q in %s", field, js)
+			}
+		}
+	}
+}
@@ -0,0 +1,285 @@
+package main
+
+import (
+	"errors"
+	"net/http"
+	"slices"
+	"strings"
+
+	"github.com/julienschmidt/httprouter"
+	"github.com/petrostrak/gomdb/internal/data"
+	"github.com/petrostrak/gomdb/internal/validator"
+	"golang.org/x/text/language"
+)
+
+// localizedName picks the name of a genre in the language which a client prefers out
+// of the given ones, falling back to its english name, or to the first of its names
+// by language when it has no english one.
+func localizedName(names map[string]string, preferred []language.Tag) string {
+	if len(names) == 0 {
+		return ""
+	}
+
+	// The english name comes first, as the matcher falls back to the first tag.
+	languages := make([]string, 0, len(names))
+	for lang := range names {
+		languages = append(languages, lang)
+	}
+
+	slices.SortFunc(languages, func(a, b string) int {
+		switch {
+		case a == "en":
+			return -1
+		case b == "en":
+			return 1
+		default:
+			return strings.Compare(a, b)
+		}
+	})
+
+	tags := make([]language.Tag, len(languages))
+
+	for i, lang := range languages {
+		tag, err := language.Parse(lang)
+		if err != nil {
+			tag = language.Und
+		}
+
+		tags[i] = tag
+	}
+
+	_, index, _ := language.NewMatcher(tags).Match(preferred...)
+
+	return names[languages[index]]
+}
+
+// localizeGenres sets the name of every genre to the one in the language the client
+// prefers, according to its Accept-Language header.
+func (app *application) localizeGenres(w http.ResponseWriter, r *http.Request, genres []*data.Genre) {
+	w.Header().Add("Vary", "Accept-Language")
+
+	// A malformed header is ignored, just like a missing one.
+	preferred, _, _ := language.ParseAcceptLanguage(r.Header.Get("Accept-Language"))
+
+	for _, genre := range genres {
+		genre.Name = localizedName(genre.Names, preferred)
+	}
+}
+
+func (app *application) createGenreHandler(w http.ResponseWriter, r *http.Request) {
+	var input struct {
+		Slug   string            `json:"slug"`
+		Parent string            `json:"parent"`
+		Names  map[string]string `json:"names"`
+	}
+
+	err := app.readJSON(w, r, &input)
+	if err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	genre := &data.Genre{
+		Slug:   input.Slug,
+		Parent: input.Parent,
+		Names:  input.Names,
+	}
+
+	v := validator.New()
+
+	if data.ValidateGenre(v, genre); !v.Valid() {
+		app.failedValidationResponse(w, r, v.Errors)
+		return
+	}
+
+	err = app.models.Genres.Insert(genre)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrDuplicateGenre):
+			v.AddError("slug", "a genre with this slug already exists")
+			app.failedValidationResponse(w, r, v.Errors)
+		case errors.Is(err, data.ErrUnknownGenre):
+			v.AddError("parent", "must reference an existing genre")
+			app.failedValidationResponse(w, r, v.Errors)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	app.localizeGenres(w, r, []*data.Genre{genre})
+
+	headers := make(http.Header)
+	headers.Set("Location", "/v1/genres/"+genre.Slug)
+
+	err = app.writeJSON(w, http.StatusCreated, envelope{"genre": genre}, headers)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+// listGenresHandler lists every genre together with the number of movies catalogued
+// under it.
+func (app *application) listGenresHandler(w http.ResponseWriter, r *http.Request) {
+	genres, err := app.models.Genres.GetAll()
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	app.localizeGenres(w, r, genres)
+
+	err = app.writeJSON(w, http.StatusOK, envelope{"genres": genres}, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+func (app *application) showGenreHandler(w http.ResponseWriter, r *http.Request) {
+	slug := httprouter.ParamsFromContext(r.Context()).ByName("slug")
+
+	genre, err := app.models.Genres.Get(slug)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrRecordNotFound):
+			app.notFoundResponse(w, r)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	app.localizeGenres(w, r, []*data.Genre{genre})
+
+	err = app.writeJSON(w, http.StatusOK, envelope{"genre": genre}, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+// updateGenreHandler changes the parent and names of a genre, and renames it when given
+// a new slug, which rewrites the genres of the movies catalogued under it.
+func (app *application) updateGenreHandler(w http.ResponseWriter, r *http.Request) {
+	slug := httprouter.ParamsFromContext(r.Context()).ByName("slug")
+
+	genre, err := app.models.Genres.Get(slug)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrRecordNotFound):
+			app.notFoundResponse(w, r)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	// An empty parent moves the genre to the top level, and names replace all of the
+	// current ones.
+	var input struct {
+		Slug   *string           `json:"slug"`
+		Parent *string           `json:"parent"`
+		Names  map[string]string `json:"names"`
+	}
+
+	err = app.readJSON(w, r, &input)
+	if err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	if input.Slug != nil {
+		genre.Slug = *input.Slug
+	}
+
+	if input.Parent != nil {
+		genre.Parent = *input.Parent
+	}
+
+	if input.Names != nil {
+		genre.Names = input.Names
+	}
+
+	v := validator.New()
+
+	if data.ValidateGenre(v, genre); !v.Valid() {
+		app.failedValidationResponse(w, r, v.Errors)
+		return
+	}
+
+	err = app.models.Genres.Update(genre, slug, app.contextGetUser(r).ID)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrDuplicateGenre):
+			v.AddError("slug", "a genre with this slug already exists")
+			app.failedValidationResponse(w, r, v.Errors)
+		case errors.Is(err, data.ErrUnknownGenre):
+			v.AddError("parent", "must reference an existing genre")
+			app.failedValidationResponse(w, r, v.Errors)
+		case errors.Is(err, data.ErrGenreCycle):
+			v.AddError("parent", "must not be one of the sub-genres of the genre")
+			app.failedValidationResponse(w, r, v.Errors)
+		case errors.Is(err, data.ErrEditConflict):
+			app.editConflictResponse(w, r)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	app.localizeGenres(w, r, []*data.Genre{genre})
+
+	err = app.writeJSON(w, http.StatusOK, envelope{"genre": genre}, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+// mergeGenreHandler folds a genre into another one, moving the movies catalogued under
+// it over, and responds with the surviving genre.
+func (app *application) mergeGenreHandler(w http.ResponseWriter, r *http.Request) {
+	slug := httprouter.ParamsFromContext(r.Context()).ByName("slug")
+
+	var input struct {
+		Into string `json:"into"`
+	}
+
+	err := app.readJSON(w, r, &input)
+	if err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	v := validator.New()
+
+	v.Check(input.Into != "", "into", "must be provided")
+	v.Check(input.Into != slug, "into", "must be a different genre")
+
+	if !v.Valid() {
+		app.failedValidationResponse(w, r, v.Errors)
+		return
+	}
+
+	err = app.models.Genres.Merge(slug, input.Into, app.contextGetUser(r).ID)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrRecordNotFound):
+			app.notFoundResponse(w, r)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	genre, err := app.models.Genres.Get(input.Into)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	app.localizeGenres(w, r, []*data.Genre{genre})
+
+	err = app.writeJSON(w, http.StatusOK, envelope{"genre": genre}, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
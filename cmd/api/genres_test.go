@@ -0,0 +1,32 @@
+package main
+
+import (
+	"testing"
+
+	"golang.org/x/text/language"
+)
+
+func Test_localizedName(t *testing.T) {
+	tests := []struct {
+		names          map[string]string
+		acceptLanguage string
+		expected       string
+	}{
+		{map[string]string{"en": "Science Fiction", "fr": "Science-fiction", "de": "Science-Fiction"}, "", "Science Fiction"},
+		{map[string]string{"en": "Science Fiction", "fr": "Science-fiction", "de": "Science-Fiction"}, "fr-CA, en;q=0.5", "Science-fiction"},
+		{map[string]string{"en": "Science Fiction", "fr": "Science-fiction", "de": "Science-Fiction"}, "ja", "Science Fiction"},
+		{map[string]string{"fr": "Polar", "it": "Poliziesco"}, "ja", "Polar"},
+		{map[string]string{"fr": "Polar", "it": "Poliziesco"}, "it", "Poliziesco"},
+		{map[string]string{}, "en", ""},
+	}
+
+	for _, tt := range tests {
+		preferred, _, _ := language.ParseAcceptLanguage(tt.acceptLanguage)
+
+		name := localizedName(tt.names, preferred)
+
+		if name != tt.expected {
+			t.Errorf("%v %q: expected name %q but got %q", tt.names, tt.acceptLanguage, tt.expected, name)
+		}
+	}
+}
@@ -9,9 +9,11 @@ import (
 	"net/url"
 	"strconv"
 	"strings"
+	"time"
 
 	"github.com/google/uuid"
 	"github.com/julienschmidt/httprouter"
+	"github.com/petrostrak/gomdb/internal/data"
 	"github.com/petrostrak/gomdb/internal/validator"
 )
 
@@ -19,11 +21,35 @@ import (
 type envelope map[string]any
 
 // Retrieve the "id" URL parameter from the current request context, then convert it to
-// an integer and return it. If the operation isn't successful, return 0 and an error.
+// a UUID and return it. If the operation isn't successful, return uuid.Nil and an error.
 func (app *application) readIDParams(r *http.Request) (uuid.UUID, error) {
+	return app.readUUIDParam(r, "id")
+}
+
+// The readUUIDParam() helper retrieves the named URL parameter from the current request
+// context and parses it as a UUID.
+func (app *application) readUUIDParam(r *http.Request, name string) (uuid.UUID, error) {
 	params := httprouter.ParamsFromContext(r.Context())
 
-	return uuid.MustParse(params.ByName("id")), nil
+	id, err := uuid.Parse(params.ByName(name))
+	if err != nil {
+		return uuid.Nil, fmt.Errorf("invalid %s parameter", name)
+	}
+
+	return id, nil
+}
+
+// The readInt64Param() helper retrieves the named URL parameter from the current
+// request context and converts it to a positive integer.
+func (app *application) readInt64Param(r *http.Request, name string) (int64, error) {
+	params := httprouter.ParamsFromContext(r.Context())
+
+	i, err := strconv.ParseInt(params.ByName(name), 10, 64)
+	if err != nil || i < 1 {
+		return 0, fmt.Errorf("invalid %s parameter", name)
+	}
+
+	return i, nil
 }
 
 // Define a writeJSON() helper for sending responses. This takes the destination
@@ -138,6 +164,116 @@ func (app *application) readInt(qs url.Values, key string, defaultValue int, v *
 	return i
 }
 
+// The readBool() helper reads a string value from the query string and converts it to a
+// boolean before returning. If no matching key could be found it returns the provided
+// default value. If the value couldn't be converted, then we record an error message in
+// the provided Validator instance.
+func (app *application) readBool(qs url.Values, key string, defaultValue bool, v *validator.Validator) bool {
+	s := qs.Get(key)
+
+	if s == "" {
+		return defaultValue
+	}
+
+	b, err := strconv.ParseBool(s)
+	if err != nil {
+		v.AddError(key, "must be a boolean value")
+		return defaultValue
+	}
+
+	return b
+}
+
+// The readUUID() helper reads a string value from the query string and parses it as a
+// UUID. If no matching key could be found it returns uuid.Nil. If the value couldn't be
+// parsed, then we record an error message in the provided Validator instance.
+func (app *application) readUUID(qs url.Values, key string, v *validator.Validator) uuid.UUID {
+	s := qs.Get(key)
+
+	if s == "" {
+		return uuid.Nil
+	}
+
+	id, err := uuid.Parse(s)
+	if err != nil {
+		v.AddError(key, "must be a valid UUID")
+		return uuid.Nil
+	}
+
+	return id
+}
+
+// The readTime() helper reads a string value from the query string and parses it as
+// either an RFC 3339 timestamp or a date, which is taken as midnight UTC. If no matching
+// key could be found it returns the zero time. If the value couldn't be parsed, then we
+// record an error message in the provided Validator instance.
+func (app *application) readTime(qs url.Values, key string, v *validator.Validator) time.Time {
+	s := qs.Get(key)
+
+	if s == "" {
+		return time.Time{}
+	}
+
+	for _, layout := range []string{time.RFC3339, time.DateOnly} {
+		t, err := time.Parse(layout, s)
+		if err == nil {
+			return t
+		}
+	}
+
+	v.AddError(key, "must be an RFC 3339 timestamp or a date")
+	return time.Time{}
+}
+
+// The readDateRange() helper reads a pair of comma-separated dates from the query string,
+// either of which may be left empty for an open-ended range. If no matching key could
+// be found it returns zero times. If the value couldn't be parsed, then we record an
+// error message in the provided Validator instance.
+func (app *application) readDateRange(qs url.Values, key string, v *validator.Validator) (from, to time.Time) {
+	s := qs.Get(key)
+
+	if s == "" {
+		return time.Time{}, time.Time{}
+	}
+
+	parts := strings.Split(s, ",")
+	if len(parts) != 2 {
+		v.AddError(key, "must be two comma-separated dates")
+		return time.Time{}, time.Time{}
+	}
+
+	dates := make([]time.Time, 2)
+
+	for i, part := range parts {
+		if part == "" {
+			continue
+		}
+
+		t, err := time.Parse(time.DateOnly, part)
+		if err != nil {
+			v.AddError(key, "must be two comma-separated dates")
+			return time.Time{}, time.Time{}
+		}
+
+		dates[i] = t
+	}
+
+	return dates[0], dates[1]
+}
+
+// The readCursor() helper reads a pagination cursor from the query string. An empty
+// value selects the first page. If the cursor couldn't be decoded, then we record an
+// error message in the provided Validator instance.
+func (app *application) readCursor(qs url.Values, key string, v *validator.Validator) *data.Cursor {
+	cursor, err := data.DecodeCursor(qs.Get(key))
+	if err != nil {
+		v.AddError(key, "must be a valid cursor")
+		return nil
+	}
+
+	return cursor
+}
+
 // background accepts an arbitrary function as parameter, spins up a background
 // goroutine, uses a deferred function to recover any panics and logs the error,
 // and executes the function itself by calling fn().
@@ -8,6 +8,7 @@ import (
 	"net/http/httptest"
 	"net/url"
 	"testing"
+	"time"
 
 	"github.com/google/uuid"
 	"github.com/julienschmidt/httprouter"
@@ -167,3 +168,93 @@ func Test_readInt(t *testing.T) {
 		}
 	}
 }
+
+func Test_readBool(t *testing.T) {
+	tests := []struct {
+		qs           url.Values
+		key          string
+		defaultValue bool
+		expected     bool
+		valid        bool
+	}{
+		{url.Values{"dry_run": []string{"true"}}, "dry_run", false, true, true},
+		{url.Values{"dry_run": []string{"0"}}, "dry_run", true, false, true},
+		{url.Values{"": []string{}}, "dry_run", true, true, true},
+		{url.Values{"dry_run": []string{"maybe"}}, "dry_run", false, false, false},
+	}
+
+	for _, tt := range tests {
+		v := validator.New()
+
+		b := app.readBool(tt.qs, tt.key, tt.defaultValue, v)
+
+		if b != tt.expected {
+			t.Errorf("expected %t but got %t\n", tt.expected, b)
+		}
+
+		if v.Valid() != tt.valid {
+			t.Errorf("expected valid to be %t but got %t\n", tt.valid, v.Valid())
+		}
+	}
+}
+
+func Test_readTime(t *testing.T) {
+	tests := []struct {
+		qs       url.Values
+		key      string
+		expected time.Time
+		valid    bool
+	}{
+		{url.Values{"created_after": []string{"2023-05-01T10:30:00Z"}}, "created_after", time.Date(2023, 5, 1, 10, 30, 0, 0, time.UTC), true},
+		{url.Values{"created_after": []string{"2023-05-01"}}, "created_after", time.Date(2023, 5, 1, 0, 0, 0, 0, time.UTC), true},
+		{url.Values{"": []string{}}, "created_after", time.Time{}, true},
+		{url.Values{"created_after": []string{"yesterday"}}, "created_after", time.Time{}, false},
+	}
+
+	for _, tt := range tests {
+		v := validator.New()
+
+		tm := app.readTime(tt.qs, tt.key, v)
+
+		if !tm.Equal(tt.expected) {
+			t.Errorf("expected %s but got %s\n", tt.expected, tm)
+		}
+
+		if v.Valid() != tt.valid {
+			t.Errorf("expected valid to be %t but got %t\n", tt.valid, v.Valid())
+		}
+	}
+}
+
+func Test_readDateRange(t *testing.T) {
+	may := time.Date(2023, 5, 1, 0, 0, 0, 0, time.UTC)
+	june := time.Date(2023, 6, 30, 0, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		qs    url.Values
+		from  time.Time
+		to    time.Time
+		valid bool
+	}{
+		{url.Values{"released_between": []string{"2023-05-01,2023-06-30"}}, may, june, true},
+		{url.Values{"released_between": []string{"2023-05-01,"}}, may, time.Time{}, true},
+		{url.Values{"released_between": []string{",2023-06-30"}}, time.Time{}, june, true},
+		{url.Values{"": []string{}}, time.Time{}, time.Time{}, true},
+		{url.Values{"released_between": []string{"2023-05-01"}}, time.Time{}, time.Time{}, false},
+		{url.Values{"released_between": []string{"2023-05-01,june"}}, time.Time{}, time.Time{}, false},
+	}
+
+	for _, tt := range tests {
+		v := validator.New()
+
+		from, to := app.readDateRange(tt.qs, "released_between", v)
+
+		if !from.Equal(tt.from) || !to.Equal(tt.to) {
+			t.Errorf("expected %s to %s but got %s to %s\n", tt.from, tt.to, from, to)
+		}
+
+		if v.Valid() != tt.valid {
+			t.Errorf("expected valid to be %t but got %t\n", tt.valid, v.Valid())
+		}
+	}
+}
@@ -0,0 +1,281 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"image"
+	"image/color"
+	"image/jpeg"
+	_ "image/png"
+	"io"
+	"net/http"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/julienschmidt/httprouter"
+	"github.com/petrostrak/gomdb/internal/data"
+	"github.com/petrostrak/gomdb/internal/validator"
+	"golang.org/x/image/draw"
+	_ "golang.org/x/image/webp"
+)
+
+// imageContentTypes maps the content types accepted for uploaded images, as sniffed
+// from their contents, to the extension the original is stored with.
+var imageContentTypes = map[string]string{
+	"image/jpeg": ".jpg",
+	"image/png":  ".png",
+	"image/webp": ".webp",
+}
+
+// maxImageDimension is the largest width or height, in pixels, of an uploaded image.
+// It keeps a small, highly compressed file from decoding into a huge bitmap.
+const maxImageDimension = 8000
+
+// imageVariantWidths holds the widths, in pixels, of the resized variants generated for
+// every kind of image. Images are never scaled up, so a variant can be narrower.
+var imageVariantWidths = map[string]map[string]int{
+	data.ImageKindPoster:   {"small": 185, "medium": 342, "large": 780},
+	data.ImageKindBackdrop: {"small": 300, "medium": 780, "large": 1280},
+}
+
+// resizeImage scales src down to the given width, keeping its aspect ratio, over a
+// white background, as JPEG has no transparency.
+func resizeImage(src image.Image, width int) image.Image {
+	bounds := src.Bounds()
+
+	if width > bounds.Dx() {
+		width = bounds.Dx()
+	}
+
+	height := max(1, bounds.Dy()*width/bounds.Dx())
+
+	dst := image.NewRGBA(image.Rect(0, 0, width, height))
+	draw.Draw(dst, dst.Bounds(), image.NewUniform(color.White), image.Point{}, draw.Src)
+	draw.CatmullRom.Scale(dst, dst.Bounds(), src, bounds, draw.Over, nil)
+
+	return dst
+}
+
+// encodeImageVariants returns the JPEG encoding of every variant of the image.
+func encodeImageVariants(src image.Image, widths map[string]int) (map[string][]byte, error) {
+	variants := make(map[string][]byte, len(widths))
+
+	for name, width := range widths {
+		var buf bytes.Buffer
+
+		err := jpeg.Encode(&buf, resizeImage(src, width), &jpeg.Options{Quality: 85})
+		if err != nil {
+			return nil, err
+		}
+
+		variants[name] = buf.Bytes()
+	}
+
+	return variants, nil
+}
+
+// setImageURLs fills in the URLs of every variant of the images from their keys.
+func (app *application) setImageURLs(images map[string]*data.MovieImage) {
+	for _, img := range images {
+		img.URLs = make(map[string]string, len(img.Keys))
+
+		for name, key := range img.Keys {
+			img.URLs[name] = app.storage.URL(key)
+		}
+	}
+}
+
+// deleteImageFiles removes the files of every variant of the images from storage.
+// Errors are only logged, as the images are already gone from the database.
+func (app *application) deleteImageFiles(images ...*data.MovieImage) {
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	for _, img := range images {
+		for _, key := range img.Keys {
+			err := app.storage.Delete(ctx, key)
+			if err != nil {
+				app.logger.PrintError(err, map[string]string{"key": key})
+			}
+		}
+	}
+}
+
+// uploadMovieImageHandler sets the poster or backdrop of a movie from the "image" field
+// of a multipart form. The uploaded image is stored as it is, together with resized
+// JPEG variants of it, and replaces any image of the same kind the movie already had.
+func (app *application) uploadMovieImageHandler(w http.ResponseWriter, r *http.Request) {
+	id, err := app.readIDParams(r)
+	if err != nil {
+		app.notFoundResponse(w, r)
+		return
+	}
+
+	kind := httprouter.ParamsFromContext(r.Context()).ByName("kind")
+	if !validator.In(kind, data.ImageKinds...) {
+		app.notFoundResponse(w, r)
+		return
+	}
+
+	_, err = app.models.Movies.Get(id)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrRecordNotFound):
+			app.notFoundResponse(w, r)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	r.Body = http.MaxBytesReader(w, r.Body, app.config.images.maxBytes)
+
+	file, _, err := r.FormFile("image")
+	if err != nil {
+		var maxBytesError *http.MaxBytesError
+
+		switch {
+		case errors.As(err, &maxBytesError):
+			app.badRequestResponse(w, r, fmt.Errorf("body must not be larger than %d bytes", maxBytesError.Limit))
+		case errors.Is(err, http.ErrMissingFile):
+			app.failedValidationResponse(w, r, map[string]string{"image": "must be provided"})
+		default:
+			app.badRequestResponse(w, r, err)
+		}
+		return
+	}
+	defer file.Close()
+
+	original, err := io.ReadAll(file)
+	if err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	// The content type is sniffed from the file itself, as the one sent by the client
+	// can't be trusted.
+	contentType := http.DetectContentType(original)
+
+	extension, ok := imageContentTypes[contentType]
+	if !ok {
+		app.unsupportedMediaTypeResponse(w, r, contentType)
+		return
+	}
+
+	config, _, err := image.DecodeConfig(bytes.NewReader(original))
+	if err != nil {
+		app.failedValidationResponse(w, r, map[string]string{"image": "must be a valid image"})
+		return
+	}
+
+	v := validator.New()
+
+	v.Check(config.Width <= maxImageDimension, "image", fmt.Sprintf("must not be more than %d pixels wide", maxImageDimension))
+	v.Check(config.Height <= maxImageDimension, "image", fmt.Sprintf("must not be more than %d pixels high", maxImageDimension))
+
+	if !v.Valid() {
+		app.failedValidationResponse(w, r, v.Errors)
+		return
+	}
+
+	src, _, err := image.Decode(bytes.NewReader(original))
+	if err != nil {
+		app.failedValidationResponse(w, r, map[string]string{"image": "must be a valid image"})
+		return
+	}
+
+	variants, err := encodeImageVariants(src, imageVariantWidths[kind])
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	// Every upload is stored under a new prefix, so that the URLs of an image never
+	// point at different contents and can be cached indefinitely.
+	prefix := fmt.Sprintf("movies/%s/%s/%s/", id, kind, uuid.New())
+
+	img := &data.MovieImage{
+		MovieID: id,
+		Kind:    kind,
+		Width:   int32(config.Width),
+		Height:  int32(config.Height),
+		Keys:    map[string]string{"original": prefix + "original" + extension},
+	}
+
+	err = app.storage.Put(r.Context(), img.Keys["original"], bytes.NewReader(original), contentType)
+	if err == nil {
+		for name, variant := range variants {
+			img.Keys[name] = prefix + name + ".jpg"
+
+			err = app.storage.Put(r.Context(), img.Keys[name], bytes.NewReader(variant), "image/jpeg")
+			if err != nil {
+				break
+			}
+		}
+	}
+	if err != nil {
+		app.deleteImageFiles(img)
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	replaced, err := app.models.Images.Save(img, app.contextGetUser(r).ID)
+	if err != nil {
+		app.deleteImageFiles(img)
+
+		switch {
+		case errors.Is(err, data.ErrRecordNotFound):
+			app.notFoundResponse(w, r)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	status := http.StatusCreated
+	if replaced != nil {
+		app.deleteImageFiles(replaced)
+		status = http.StatusOK
+	}
+
+	app.setImageURLs(map[string]*data.MovieImage{kind: img})
+
+	err = app.writeJSON(w, status, envelope{"image": img}, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+func (app *application) deleteMovieImageHandler(w http.ResponseWriter, r *http.Request) {
+	id, err := app.readIDParams(r)
+	if err != nil {
+		app.notFoundResponse(w, r)
+		return
+	}
+
+	kind := httprouter.ParamsFromContext(r.Context()).ByName("kind")
+	if !validator.In(kind, data.ImageKinds...) {
+		app.notFoundResponse(w, r)
+		return
+	}
+
+	img, err := app.models.Images.Delete(id, kind, app.contextGetUser(r).ID)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrRecordNotFound):
+			app.notFoundResponse(w, r)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	app.deleteImageFiles(img)
+
+	err = app.writeJSON(w, http.StatusOK, envelope{"message": "successfully deleted"}, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
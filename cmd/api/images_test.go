@@ -0,0 +1,37 @@
+package main
+
+import (
+	"bytes"
+	"image"
+	"image/jpeg"
+	"testing"
+)
+
+func Test_encodeImageVariants(t *testing.T) {
+	src := image.NewNRGBA(image.Rect(0, 0, 500, 750))
+
+	variants, err := encodeImageVariants(src, map[string]int{"small": 100, "large": 1000})
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	tests := []struct {
+		name   string
+		width  int
+		height int
+	}{
+		{"small", 100, 150},
+		{"large", 500, 750},
+	}
+
+	for _, tt := range tests {
+		config, err := jpeg.DecodeConfig(bytes.NewReader(variants[tt.name]))
+		if err != nil {
+			t.Fatalf("%s: cannot decode variant: %s", tt.name, err)
+		}
+
+		if config.Width != tt.width || config.Height != tt.height {
+			t.Errorf("%s: expected %dx%d but got %dx%d", tt.name, tt.width, tt.height, config.Width, config.Height)
+		}
+	}
+}
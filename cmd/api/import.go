@@ -0,0 +1,383 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/google/uuid"
+	"github.com/petrostrak/gomdb/internal/data"
+	"github.com/petrostrak/gomdb/internal/validator"
+)
+
+const (
+	importModeAtomic     = "atomic"
+	importModeBestEffort = "best_effort"
+)
+
+// importReport is sent back to the client once an import has finished, with one row
+// for every movie found in the uploaded file.
+type importReport struct {
+	Mode    string      `json:"mode"`
+	DryRun  bool        `json:"dry_run"`
+	Total   int         `json:"total"`
+	Created int         `json:"created"`
+	Failed  int         `json:"failed"`
+	Rows    []importRow `json:"rows"`
+}
+
+type importRow struct {
+	Line       int               `json:"line"`
+	Status     string            `json:"status"`
+	ID         *uuid.UUID        `json:"id,omitempty"`
+	Errors     map[string]string `json:"errors,omitempty"`
+	Duplicates []*data.Movie     `json:"duplicates,omitempty"`
+}
+
+// movieReader yields the movies held in an import file one row at a time.
+type movieReader interface {
+	// Next returns the line the next row starts on, the movie decoded from it and any
+	// problems found while decoding it. The movie is nil when the row couldn't be
+	// decoded at all. Once every row has been read, Next returns io.EOF.
+	Next() (int, *data.Movie, map[string]string, error)
+}
+
+// newMovieReader returns a movieReader for the given content type, which must be
+// either text/csv or application/x-ndjson.
+func newMovieReader(contentType string, body io.Reader) (movieReader, error) {
+	switch contentType {
+	case "text/csv":
+		return newCSVMovieReader(body)
+	case "application/x-ndjson", "application/ndjson":
+		return newNDJSONMovieReader(body), nil
+	default:
+		return nil, errUnsupportedContentType
+	}
+}
+
+var errUnsupportedContentType = errors.New("unsupported content type")
+
+// csvMovieReader reads movies from CSV files which start with a header row naming the
+// title, year, runtime and genres columns. Genres are separated by commas within their
+// (quoted) field, and runtimes are given in minutes.
+type csvMovieReader struct {
+	r       *csv.Reader
+	columns map[string]int
+}
+
+func newCSVMovieReader(body io.Reader) (*csvMovieReader, error) {
+	r := csv.NewReader(body)
+	r.FieldsPerRecord = -1
+	r.TrimLeadingSpace = true
+
+	header, err := r.Read()
+	if err != nil {
+		switch {
+		case errors.Is(err, io.EOF):
+			return nil, errors.New("body must not be empty")
+		default:
+			return nil, err
+		}
+	}
+
+	columns := make(map[string]int)
+	for i, name := range header {
+		columns[strings.ToLower(strings.TrimSpace(name))] = i
+	}
+
+	for _, name := range []string{"title", "year", "runtime", "genres"} {
+		if _, ok := columns[name]; !ok {
+			return nil, fmt.Errorf("csv header is missing the %q column", name)
+		}
+	}
+
+	return &csvMovieReader{r: r, columns: columns}, nil
+}
+
+func (c *csvMovieReader) Next() (int, *data.Movie, map[string]string, error) {
+	record, err := c.r.Read()
+	if err != nil {
+		var parseError *csv.ParseError
+
+		switch {
+		case errors.As(err, &parseError):
+			return parseError.StartLine, nil, map[string]string{"row": parseError.Err.Error()}, nil
+		default:
+			return 0, nil, nil, err
+		}
+	}
+
+	line, _ := c.r.FieldPos(0)
+
+	field := func(name string) string {
+		if i, ok := c.columns[name]; ok && i < len(record) {
+			return strings.TrimSpace(record[i])
+		}
+		return ""
+	}
+
+	movie := &data.Movie{Title: field("title"), Status: data.MovieStatusReleased}
+	errs := make(map[string]string)
+
+	// The status column is optional, and movies are taken to be released without it.
+	if s := field("status"); s != "" {
+		movie.Status = s
+	}
+
+	if s := field("year"); s != "" {
+		year, err := strconv.ParseInt(s, 10, 32)
+		if err != nil {
+			errs["year"] = "must be an integer"
+		}
+		movie.Year = int32(year)
+	}
+
+	if s := field("runtime"); s != "" {
+		runtime, err := strconv.ParseInt(strings.TrimSuffix(s, " mins"), 10, 32)
+		if err != nil {
+			errs["runtime"] = "must be an integer number of minutes"
+		}
+		movie.Runtime = data.Runtime(runtime)
+	}
+
+	if s := field("genres"); s != "" {
+		for _, genre := range strings.Split(s, ",") {
+			movie.Genres = append(movie.Genres, strings.TrimSpace(genre))
+		}
+	}
+
+	return line, movie, errs, nil
+}
+
+// ndjsonMovieReader reads movies from newline-delimited JSON, where every non-blank
+// line holds a movie in the same format accepted by createMovieHandler.
+type ndjsonMovieReader struct {
+	scanner *bufio.Scanner
+	line    int
+}
+
+func newNDJSONMovieReader(body io.Reader) *ndjsonMovieReader {
+	scanner := bufio.NewScanner(body)
+	scanner.Buffer(make([]byte, 64*1024), 1_048_576)
+
+	return &ndjsonMovieReader{scanner: scanner}
+}
+
+func (n *ndjsonMovieReader) Next() (int, *data.Movie, map[string]string, error) {
+	for n.scanner.Scan() {
+		n.line++
+
+		line := bytes.TrimSpace(n.scanner.Bytes())
+		if len(line) == 0 {
+			continue
+		}
+
+		var input struct {
+			Title   string       `json:"title"`
+			Year    int32        `json:"year"`
+			Runtime data.Runtime `json:"runtime"`
+			Genres  []string     `json:"genres"`
+			Status  string       `json:"status"`
+		}
+
+		dec := json.NewDecoder(bytes.NewReader(line))
+		dec.DisallowUnknownFields()
+
+		err := dec.Decode(&input)
+		if err != nil {
+			return n.line, nil, map[string]string{"row": strings.TrimPrefix(err.Error(), "json: ")}, nil
+		}
+
+		if input.Status == "" {
+			input.Status = data.MovieStatusReleased
+		}
+
+		movie := &data.Movie{
+			Title:   input.Title,
+			Year:    input.Year,
+			Runtime: input.Runtime,
+			Genres:  input.Genres,
+			Status:  input.Status,
+		}
+
+		return n.line, movie, nil, nil
+	}
+
+	if err := n.scanner.Err(); err != nil {
+		if errors.Is(err, bufio.ErrTooLong) {
+			return 0, nil, nil, fmt.Errorf("line %d must not be larger than 1048576 bytes", n.line+1)
+		}
+		return 0, nil, nil, err
+	}
+
+	return 0, nil, nil, io.EOF
+}
+
+// importMoviesHandler streams a CSV or NDJSON file of movies from the request body and
+// validates every row with data.ValidateMovie. In atomic mode the movies are only saved
+// if every row is valid, while in best_effort mode the valid rows are saved regardless.
+// Rows which may duplicate an existing movie count as failed unless ?force=true is given.
+// A dry run validates the file without saving anything.
+func (app *application) importMoviesHandler(w http.ResponseWriter, r *http.Request) {
+	v := validator.New()
+
+	qs := r.URL.Query()
+
+	mode := app.readString(qs, "mode", importModeAtomic)
+	dryRun := app.readBool(qs, "dry_run", false, v)
+	force := app.readBool(qs, "force", false, v)
+
+	v.Check(validator.In(mode, importModeAtomic, importModeBestEffort), "mode", "must be either atomic or best_effort")
+
+	if !v.Valid() {
+		app.failedValidationResponse(w, r, v.Errors)
+		return
+	}
+
+	contentType, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type"))
+
+	r.Body = http.MaxBytesReader(w, r.Body, app.config.imports.maxBytes)
+
+	reader, err := newMovieReader(contentType, r.Body)
+	if err != nil {
+		switch {
+		case errors.Is(err, errUnsupportedContentType):
+			app.unsupportedMediaTypeResponse(w, r, contentType)
+		default:
+			app.badRequestResponse(w, r, err)
+		}
+		return
+	}
+
+	genres, err := app.models.Genres.Slugs()
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	user := app.contextGetUser(r)
+
+	// In atomic mode the valid movies are held back, together with the index of their
+	// row in the report, until the whole file has been read and validated, so that the
+	// transaction saving them isn't kept open while the body is still streaming in.
+	var pending []*data.Movie
+	var pendingRows []int
+
+	report := importReport{Mode: mode, DryRun: dryRun, Rows: []importRow{}}
+
+	for {
+		line, movie, errs, err := reader.Next()
+		if err != nil {
+			var maxBytesError *http.MaxBytesError
+
+			switch {
+			case errors.Is(err, io.EOF):
+			case errors.As(err, &maxBytesError):
+				app.badRequestResponse(w, r, fmt.Errorf("body must not be larger than %d bytes", maxBytesError.Limit))
+				return
+			default:
+				app.badRequestResponse(w, r, err)
+				return
+			}
+			break
+		}
+
+		report.Total++
+		row := importRow{Line: line}
+
+		v := validator.New()
+		for key, message := range errs {
+			v.AddError(key, message)
+		}
+
+		if movie != nil {
+			data.ValidateMovie(v, movie, genres)
+		}
+
+		if !v.Valid() {
+			row.Status = "invalid"
+			row.Errors = v.Errors
+			report.Failed++
+			report.Rows = append(report.Rows, row)
+			continue
+		}
+
+		// Rows which probably duplicate an existing movie are refused just like a single
+		// movie would be by createMovieHandler, unless the client confirms them with
+		// ?force=true. Duplicates within the file itself aren't looked for.
+		if !force {
+			duplicates, err := app.models.Movies.FindDuplicates(movie)
+			if err != nil {
+				app.serverErrorResponse(w, r, err)
+				return
+			}
+
+			if len(duplicates) > 0 {
+				row.Status = "duplicate"
+				row.Duplicates = duplicates
+				report.Failed++
+				report.Rows = append(report.Rows, row)
+				continue
+			}
+		}
+
+		switch {
+		case dryRun:
+			row.Status = "valid"
+		case mode == importModeAtomic:
+			row.Status = "valid"
+			pending = append(pending, movie)
+			pendingRows = append(pendingRows, len(report.Rows))
+		default:
+			err = app.models.Movies.Insert(movie, user.ID)
+			if err != nil {
+				app.logError(r, err)
+				row.Status = "invalid"
+				row.Errors = map[string]string{"row": "could not be saved"}
+				report.Failed++
+				break
+			}
+
+			row.Status = "created"
+			row.ID = &movie.ID
+			report.Created++
+		}
+
+		report.Rows = append(report.Rows, row)
+	}
+
+	status := http.StatusOK
+
+	if mode == importModeAtomic && !dryRun {
+		if report.Failed > 0 {
+			// Nothing is saved, and the valid rows are reported as such.
+			status = http.StatusUnprocessableEntity
+		} else if len(pending) > 0 {
+			err = app.models.Movies.InsertAll(pending, user.ID)
+			if err != nil {
+				app.serverErrorResponse(w, r, err)
+				return
+			}
+
+			for i, movie := range pending {
+				row := &report.Rows[pendingRows[i]]
+				row.Status = "created"
+				row.ID = &movie.ID
+			}
+			report.Created = len(pending)
+		}
+	}
+
+	err = app.writeJSON(w, status, envelope{"import": report}, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
@@ -0,0 +1,69 @@
+package main
+
+import (
+	"errors"
+	"io"
+	"strings"
+	"testing"
+)
+
+func Test_newMovieReader(t *testing.T) {
+	tests := []struct {
+		name        string
+		contentType string
+		body        string
+		lines       []int
+		invalid     []string
+	}{
+		{
+			name:        "csv",
+			contentType: "text/csv",
+			body:        "title,year,runtime,genres\nAlien,1979,117,\"horror,sci-fi\"\nHeat,abc,170,crime\n",
+			lines:       []int{2, 3},
+			invalid:     []string{"", "year"},
+		},
+		{
+			name:        "ndjson",
+			contentType: "application/x-ndjson",
+			body:        "{\"title\":\"Alien\",\"year\":1979,\"runtime\":\"117 mins\",\"genres\":[\"horror\"]}\n\n{\"title\":\"Heat\",\"rating\":5}\n",
+			lines:       []int{1, 3},
+			invalid:     []string{"", "row"},
+		},
+	}
+
+	for _, tt := range tests {
+		reader, err := newMovieReader(tt.contentType, strings.NewReader(tt.body))
+		if err != nil {
+			t.Fatalf("%s: unexpected error: %s", tt.name, err)
+		}
+
+		for i := range tt.lines {
+			line, _, errs, err := reader.Next()
+			if err != nil {
+				t.Fatalf("%s: unexpected error: %s", tt.name, err)
+			}
+
+			if line != tt.lines[i] {
+				t.Errorf("%s: expected line %d but got %d", tt.name, tt.lines[i], line)
+			}
+
+			if _, ok := errs[tt.invalid[i]]; tt.invalid[i] != "" && !ok {
+				t.Errorf("%s: expected an error for %q on line %d", tt.name, tt.invalid[i], line)
+			}
+		}
+
+		if _, _, _, err := reader.Next(); !errors.Is(err, io.EOF) {
+			t.Errorf("%s: expected io.EOF but got %v", tt.name, err)
+		}
+	}
+
+	_, err := newMovieReader("application/json", strings.NewReader("{}"))
+	if !errors.Is(err, errUnsupportedContentType) {
+		t.Errorf("expected errUnsupportedContentType but got %v", err)
+	}
+
+	_, err = newMovieReader("text/csv", strings.NewReader("title,year\n"))
+	if err == nil {
+		t.Error("did not get an error for a csv header with missing columns")
+	}
+}
@@ -0,0 +1,72 @@
+package main
+
+import (
+	"fmt"
+	"strconv"
+	"time"
+)
+
+// purgeTrash permanently deletes the movies which have been in the trash for longer
+// than the configured retention period, together with their images. It runs for the
+// lifetime of the application, once every purge interval.
+func (app *application) purgeTrash() {
+	for {
+		app.runJob(app.purgeTrashOnce)
+
+		time.Sleep(app.config.trash.purgeInterval)
+	}
+}
+
+func (app *application) purgeTrashOnce() {
+	before := time.Now().Add(-app.config.trash.retention)
+
+	images, count, err := app.models.Movies.Purge(before)
+	if err != nil {
+		app.logger.PrintError(err, nil)
+		return
+	}
+
+	app.deleteImageFiles(images...)
+
+	if count > 0 {
+		app.logger.PrintInfo("purged movies from trash", map[string]string{
+			"count": strconv.FormatInt(count, 10),
+		})
+	}
+}
+
+// purgeIdempotencyKeys deletes the expired idempotency keys, along with the responses
+// stored with them. It runs for the lifetime of the application, once every purge
+// interval.
+func (app *application) purgeIdempotencyKeys() {
+	for {
+		app.runJob(app.purgeIdempotencyKeysOnce)
+
+		time.Sleep(app.config.idempotency.purgeInterval)
+	}
+}
+
+func (app *application) purgeIdempotencyKeysOnce() {
+	count, err := app.models.Idempotency.DeleteExpired()
+	if err != nil {
+		app.logger.PrintError(err, nil)
+	} else if count > 0 {
+		app.logger.PrintInfo("purged expired idempotency keys", map[string]string{
+			"count": strconv.FormatInt(count, 10),
+		})
+	}
+}
+
+// runJob runs a single iteration of a periodic job, logging a panic rather than letting
+// it take the application down, so that the job carries on with its next iteration.
+// Jobs run for the lifetime of the application, so unlike background tasks they aren't
+// waited for on shutdown.
+func (app *application) runJob(fn func()) {
+	defer func() {
+		if err := recover(); err != nil {
+			app.logger.PrintError(fmt.Errorf("%s", err), nil)
+		}
+	}()
+
+	fn()
+}
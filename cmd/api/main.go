@@ -16,6 +16,7 @@ import (
 	"github.com/petrostrak/gomdb/internal/data"
 	"github.com/petrostrak/gomdb/internal/jsonlog"
 	"github.com/petrostrak/gomdb/internal/mailer"
+	"github.com/petrostrak/gomdb/internal/storage"
 )
 
 var (
@@ -48,16 +49,35 @@ type config struct {
 	cors struct {
 		trustedOrigins []string
 	}
+	imports struct {
+		maxBytes int64
+	}
+	trash struct {
+		retention     time.Duration
+		purgeInterval time.Duration
+	}
+	idempotency struct {
+		ttl           time.Duration
+		purgeInterval time.Duration
+	}
+	images struct {
+		maxBytes int64
+	}
+	storage struct {
+		dir     string
+		baseURL string
+	}
 }
 
 // An application struct that holds all the dependencies for the HTTP handlers,
 // helpers and middlewares.
 type application struct {
-	config config
-	logger *jsonlog.Logger
-	models data.Models
-	mailer mailer.Mailer
-	wg     sync.WaitGroup
+	config  config
+	logger  *jsonlog.Logger
+	models  data.Models
+	mailer  mailer.Mailer
+	storage storage.Storage
+	wg      sync.WaitGroup
 }
 
 func main() {
@@ -82,6 +102,19 @@ func main() {
 	flag.StringVar(&cfg.smtp.password, "smtp-password", "64d1e075467206", "SMTP password")
 	flag.StringVar(&cfg.smtp.sender, "smtp-sender", "Go-MDB <no-reply@gomdb.petros_trak.net>", "SMTP sender")
 
+	flag.Int64Var(&cfg.imports.maxBytes, "import-max-bytes", 50*1_048_576, "Maximum size of a movie import file in bytes")
+
+	flag.DurationVar(&cfg.trash.retention, "trash-retention", 30*24*time.Hour, "How long deleted movies are kept in the trash")
+	flag.DurationVar(&cfg.trash.purgeInterval, "trash-purge-interval", time.Hour, "How often expired movies are purged from the trash")
+
+	flag.DurationVar(&cfg.idempotency.ttl, "idempotency-key-ttl", 24*time.Hour, "How long idempotency keys and their stored responses are kept")
+	flag.DurationVar(&cfg.idempotency.purgeInterval, "idempotency-purge-interval", time.Hour, "How often expired idempotency keys are purged")
+
+	flag.Int64Var(&cfg.images.maxBytes, "image-max-bytes", 10*1_048_576, "Maximum size of an uploaded movie image in bytes")
+
+	flag.StringVar(&cfg.storage.dir, "storage-dir", "./uploads", "Directory where uploaded files are stored")
+	flag.StringVar(&cfg.storage.baseURL, "storage-base-url", "/v1/images", "Base URL of the stored files, which the API serves itself under /v1/images")
+
 	displayVersion := flag.Bool("version", false, "Display version and exit")
 
 	flag.Func("cors-trusted-origins", "Trusted CORS origins (space separated)", func(s string) error {
@@ -114,13 +147,23 @@ func main() {
 	}))
 
 	logger.PrintInfo("database connection pool established", nil)
+
+	store, err := storage.NewLocal(cfg.storage.dir, cfg.storage.baseURL)
+	if err != nil {
+		logger.PrintFatal(err, nil)
+	}
+
 	app := &application{
-		config: cfg,
-		logger: logger,
-		models: data.NewModels(db),
-		mailer: mailer.New(cfg.smtp.host, cfg.smtp.port, cfg.smtp.username, cfg.smtp.password, cfg.smtp.sender),
+		config:  cfg,
+		logger:  logger,
+		models:  data.NewModels(db),
+		mailer:  mailer.New(cfg.smtp.host, cfg.smtp.port, cfg.smtp.username, cfg.smtp.password, cfg.smtp.sender),
+		storage: store,
 	}
 
+	go app.purgeTrash()
+	go app.purgeIdempotencyKeys()
+
 	err = app.serve()
 	if err != nil {
 		logger.PrintFatal(err, nil)
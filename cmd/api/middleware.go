@@ -1,9 +1,12 @@
 package main
 
 import (
+	"bytes"
+	"crypto/sha256"
 	"errors"
 	"expvar"
 	"fmt"
+	"io"
 	"net/http"
 	"strconv"
 	"strings"
@@ -173,6 +176,136 @@ func (app *application) requirePermission(code string, next http.HandlerFunc) ht
 	return app.requireActivatedUser(fn)
 }
 
+// idempotencyReplayedHeaders are the response headers stored with an idempotency key
+// and sent again when the response is replayed.
+var idempotencyReplayedHeaders = []string{"Content-Type", "Location", "ETag", "Last-Modified"}
+
+// idempotent lets clients safely retry a request by sending an Idempotency-Key header.
+// The response to the first request with a key is stored, and any retry with the same
+// key, query string and body gets the stored response instead of being processed again.
+// Reusing a key with a different query string or body is rejected. Server errors and
+// conflicts aren't stored, so the request can be retried, for instance with ?force=true
+// after being told about duplicates. It must run after authenticate, as keys are scoped
+// per user. Anonymous requests, such as registrations, are processed without it, as
+// they all share the same user and could otherwise replay each other's responses.
+func (app *application) idempotent(next http.HandlerFunc) http.HandlerFunc {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		key := r.Header.Get("Idempotency-Key")
+
+		if key == "" || app.contextGetUser(r).IsAnonymous() {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		v := validator.New()
+
+		if v.Check(len(key) <= 255, "Idempotency-Key", "must not be more than 255 bytes long"); !v.Valid() {
+			app.failedValidationResponse(w, r, v.Errors)
+			return
+		}
+
+		body, err := io.ReadAll(http.MaxBytesReader(w, r.Body, 1_048_576))
+		if err != nil {
+			app.badRequestResponse(w, r, errors.New("body must not be larger than 1048576 bytes"))
+			return
+		}
+		r.Body = io.NopCloser(bytes.NewReader(body))
+
+		// The query string is part of the request too, as parameters such as force
+		// change what the handler does.
+		hash := sha256.Sum256(append([]byte(r.URL.RawQuery+"\n"), body...))
+
+		record := &data.IdempotencyKey{
+			UserID:      app.contextGetUser(r).ID,
+			Route:       r.Method + " " + r.URL.Path,
+			Key:         key,
+			RequestHash: hash[:],
+			Expiry:      time.Now().Add(app.config.idempotency.ttl),
+		}
+
+		existing, err := app.models.Idempotency.Begin(record)
+		if err != nil {
+			app.serverErrorResponse(w, r, err)
+			return
+		}
+
+		if existing != nil {
+			switch {
+			case !bytes.Equal(existing.RequestHash, record.RequestHash):
+				app.errorResponse(w, r, http.StatusUnprocessableEntity, "the idempotency key has already been used for a different request")
+			case existing.Status == 0:
+				app.errorResponse(w, r, http.StatusConflict, "a request with the same idempotency key is still being processed")
+			default:
+				for key, value := range existing.Headers {
+					w.Header().Set(key, value)
+				}
+				w.Header().Set("Idempotent-Replayed", "true")
+				w.WriteHeader(existing.Status)
+				w.Write(existing.Body)
+			}
+			return
+		}
+
+		// If the handler panics, give the key up so that the request can be retried.
+		completed := false
+		defer func() {
+			if !completed {
+				err := app.models.Idempotency.Release(record)
+				if err != nil {
+					app.logError(r, err)
+				}
+			}
+		}()
+
+		rec := &responseRecorder{ResponseWriter: w, status: http.StatusOK}
+
+		next.ServeHTTP(rec, r)
+
+		completed = true
+
+		if rec.status >= http.StatusInternalServerError || rec.status == http.StatusConflict {
+			err = app.models.Idempotency.Release(record)
+		} else {
+			record.Status = rec.status
+			record.Body = rec.body.Bytes()
+			record.Headers = make(map[string]string)
+
+			for _, key := range idempotencyReplayedHeaders {
+				if value := w.Header().Get(key); value != "" {
+					record.Headers[key] = value
+				}
+			}
+
+			err = app.models.Idempotency.Complete(record)
+		}
+		if err != nil {
+			app.logError(r, err)
+		}
+	})
+}
+
+// responseRecorder passes a response through to the underlying ResponseWriter while
+// keeping a copy of its status code and body.
+type responseRecorder struct {
+	http.ResponseWriter
+	status int
+	body   bytes.Buffer
+}
+
+func (rec *responseRecorder) WriteHeader(status int) {
+	rec.status = status
+	rec.ResponseWriter.WriteHeader(status)
+}
+
+func (rec *responseRecorder) Write(b []byte) (int, error) {
+	rec.body.Write(b)
+	return rec.ResponseWriter.Write(b)
+}
+
+func (rec *responseRecorder) Unwrap() http.ResponseWriter {
+	return rec.ResponseWriter
+}
+
 func (app *application) enableCORS(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		w.Header().Add("Vary", "Origin")
@@ -184,10 +317,11 @@ func (app *application) enableCORS(next http.Handler) http.Handler {
 			for i := range app.config.cors.trustedOrigins {
 				if origin == app.config.cors.trustedOrigins[i] {
 					w.Header().Set("Access-Control-Allow-Origin", origin)
+					w.Header().Set("Access-Control-Expose-Headers", "ETag")
 
 					if r.Method == http.MethodOptions && r.Header.Get("Access-Control-Request-Method") != "" {
 						w.Header().Set("Access-Control-Allow-Methods", "OPTIONS, PUT, PATCH, DELETE")
-						w.Header().Set("Access-Control-Allow-Headers", "Authorization, Content-Type")
+						w.Header().Set("Access-Control-Allow-Headers", "Authorization, Content-Type, If-Match, If-None-Match")
 						w.WriteHeader(http.StatusOK)
 						return
 					}
@@ -3,18 +3,57 @@ package main
 import (
 	"errors"
 	"fmt"
+	"mime"
 	"net/http"
+	"net/url"
+	"strings"
 
+	jsonpatch "github.com/evanphx/json-patch/v5"
+	"github.com/google/uuid"
+	"github.com/julienschmidt/httprouter"
 	"github.com/petrostrak/gomdb/internal/data"
 	"github.com/petrostrak/gomdb/internal/validator"
 )
 
+// movieSortSafelist holds the sort values accepted wherever movies are listed.
+// Relevance is always sorted from the best match down, so it has no descending form.
+var movieSortSafelist = []string{"id", "title", "year", "runtime", "rating", "relevance", "-id", "-title", "-year", "-runtime", "-rating"}
+
+// readMovieFilters reads and validates the filters which can be applied wherever movies
+// are listed.
+func (app *application) readMovieFilters(qs url.Values, v *validator.Validator) data.MovieFilters {
+	mf := data.MovieFilters{
+		Title:         app.readString(qs, "title", ""),
+		Genres:        app.readCSV(qs, "genres", []string{}),
+		GenresAny:     app.readCSV(qs, "genres_any", []string{}),
+		ExcludeGenres: app.readCSV(qs, "exclude_genres", []string{}),
+		PersonID:      app.readUUID(qs, "person", v),
+		Role:          app.readString(qs, "role", ""),
+		Language:      app.readString(qs, "lang", data.SearchLanguageSimple),
+		YearMin:       app.readInt(qs, "year_min", 0, v),
+		YearMax:       app.readInt(qs, "year_max", 0, v),
+		RuntimeMin:    app.readInt(qs, "runtime_min", 0, v),
+		RuntimeMax:    app.readInt(qs, "runtime_max", 0, v),
+		CreatedAfter:  app.readTime(qs, "created_after", v),
+		CreatedBefore: app.readTime(qs, "created_before", v),
+		ReleasedIn:    app.readString(qs, "released_in", ""),
+	}
+
+	mf.ReleasedFrom, mf.ReleasedTo = app.readDateRange(qs, "released_between", v)
+
+	data.ValidateMovieFilters(v, mf)
+
+	return mf
+}
+
 func (app *application) createMovieHandler(w http.ResponseWriter, r *http.Request) {
 	var input struct {
-		Title   string       `json:"title"`
-		Year    int32        `json:"year"`
-		Runtime data.Runtime `json:"runtime"`
-		Genres  []string     `json:"genres"`
+		Title       string            `json:"title"`
+		Year        int32             `json:"year"`
+		Runtime     data.Runtime      `json:"runtime"`
+		Genres      []string          `json:"genres"`
+		Status      string            `json:"status"`
+		ExternalIDs map[string]string `json:"external_ids"`
 	}
 
 	err := app.readJSON(w, r, &input)
@@ -23,32 +62,91 @@ func (app *application) createMovieHandler(w http.ResponseWriter, r *http.Reques
 		return
 	}
 
+	// A movie is taken to be released unless the client says otherwise.
+	if input.Status == "" {
+		input.Status = data.MovieStatusReleased
+	}
+
 	movie := &data.Movie{
-		Title:   input.Title,
-		Year:    input.Year,
-		Runtime: input.Runtime,
-		Genres:  input.Genres,
+		Title:       input.Title,
+		Year:        input.Year,
+		Runtime:     input.Runtime,
+		Genres:      input.Genres,
+		Status:      input.Status,
+		ExternalIDs: input.ExternalIDs,
+	}
+
+	genres, err := app.models.Genres.Slugs()
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
 	}
 
 	v := validator.New()
 
-	if data.ValidateMovie(v, movie); !v.Valid() {
+	force := app.readBool(r.URL.Query(), "force", false, v)
+
+	if data.ValidateMovie(v, movie, genres); !v.Valid() {
 		app.failedValidationResponse(w, r, v.Errors)
 		return
 	}
 
-	err = app.models.Movies.Insert(movie)
+	// Refuse to create a movie which probably exists already, unless the client has
+	// checked the candidates and confirmed that it's a different one with ?force=true.
+	duplicates, err := app.models.Movies.FindDuplicates(movie)
 	if err != nil {
 		app.serverErrorResponse(w, r, err)
 		return
 	}
 
+	if len(duplicates) > 0 && !force {
+		env := envelope{
+			"error":      "the movie may already exist, send the request again with ?force=true to create it anyway",
+			"duplicates": duplicates,
+		}
+
+		err = app.writeJSON(w, http.StatusConflict, env, nil)
+		if err != nil {
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	err = app.models.Movies.Insert(movie, app.contextGetUser(r).ID)
+	if err != nil {
+		var trashedError *data.TrashedExternalIDError
+
+		switch {
+		case errors.As(err, &trashedError):
+			v.AddError("external_ids", fmt.Sprintf("an external id is already used by movie %s, which is in the trash and can be restored instead", trashedError.MovieID))
+			app.failedValidationResponse(w, r, v.Errors)
+		case errors.Is(err, data.ErrDuplicateExternalID):
+			v.AddError("external_ids", "an external id is already used by another movie")
+			app.failedValidationResponse(w, r, v.Errors)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
 	// When sending a HTTP response, we want to include a Location header to let the
 	// client know which URL they can find the newly-created resource at.
 	headers := make(http.Header)
-	headers.Set("Location", fmt.Sprintf("/v1/movies/%d", movie.ID))
+	headers.Set("Location", fmt.Sprintf("/v1/movies/%s", movie.ID))
+
+	err = setMovieValidators(headers, movie, movie)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
 
-	err = app.writeJSON(w, http.StatusCreated, envelope{"movie": movie}, headers)
+	env := envelope{"movie": movie}
+
+	if len(duplicates) > 0 {
+		env["duplicates"] = duplicates
+	}
+
+	err = app.writeJSON(w, http.StatusCreated, env, headers)
 	if err != nil {
 		app.serverErrorResponse(w, r, err)
 	}
@@ -61,23 +159,66 @@ func (app *application) showMovieHandler(w http.ResponseWriter, r *http.Request)
 		return
 	}
 
+	v := validator.New()
+
+	fields, include := app.readMovieFieldset(r.URL.Query(), v)
+
+	if !v.Valid() {
+		app.failedValidationResponse(w, r, v.Errors)
+		return
+	}
+
 	movie, err := app.models.Movies.Get(id)
 	if err != nil {
 		switch {
 		case errors.Is(err, data.ErrRecordNotFound):
-			app.notFoundResponse(w, r)
+			app.redirectMergedMovie(w, r, id)
 		default:
 			app.serverErrorResponse(w, r, err)
 		}
 		return
 	}
 
-	err = app.writeJSON(w, http.StatusOK, envelope{"movie": movie}, nil)
+	err = app.localizeMovies(w, r, []*data.Movie{movie})
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	err = app.embedMovieResources([]*data.Movie{movie}, include)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	trimmed, err := trimMovies([]*data.Movie{movie}, fields, include)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	headers := make(http.Header)
+
+	err = setMovieValidators(headers, movie, trimmed[0])
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	if notModified(r, headers.Get("ETag"), movie.UpdatedAt) {
+		writeNotModified(w, headers)
+		return
+	}
+
+	err = app.writeJSON(w, http.StatusOK, envelope{"movie": trimmed[0]}, headers)
 	if err != nil {
 		app.serverErrorResponse(w, r, err)
 	}
 }
 
+// updateMovieHandler applies a partial update to a movie. Besides a plain JSON object,
+// whose fields replace the current ones, it accepts a JSON Merge Patch or a JSON Patch,
+// selected by the Content-Type header.
 func (app *application) updateMovieHandler(w http.ResponseWriter, r *http.Request) {
 	id, err := app.readIDParams(r)
 	if err != nil {
@@ -96,45 +237,172 @@ func (app *application) updateMovieHandler(w http.ResponseWriter, r *http.Reques
 		return
 	}
 
-	var input struct {
-		Title   *string       `json:"title"`
-		Year    *int32        `json:"year"`
-		Runtime *data.Runtime `json:"runtime"`
-		Genres  []string      `json:"genres"`
+	if ifMatchFailed(r, movieVersionTag(movie)) {
+		app.preconditionFailedResponse(w, r)
+		return
 	}
 
-	err = app.readJSON(w, r, &input)
+	contentType, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type"))
+
+	switch contentType {
+	case mergePatchContentType, jsonPatchContentType:
+		err = app.readMoviePatch(w, r, contentType, movie)
+	default:
+		err = app.readMovieUpdate(w, r, movie)
+	}
 	if err != nil {
-		app.badRequestResponse(w, r, err)
+		switch {
+		case errors.Is(err, jsonpatch.ErrTestFailed):
+			app.editConflictResponse(w, r)
+		default:
+			app.badRequestResponse(w, r, err)
+		}
+		return
+	}
+
+	genres, err := app.models.Genres.Slugs()
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
 		return
 	}
 
-	if input.Title != nil {
-		movie.Title = *input.Title
+	v := validator.New()
+
+	if data.ValidateMovie(v, movie, genres); !v.Valid() {
+		app.failedValidationResponse(w, r, v.Errors)
+		return
 	}
 
-	if input.Year != nil {
-		movie.Year = *input.Year
+	err = app.models.Movies.Update(movie, app.contextGetUser(r).ID)
+	if err != nil {
+		var trashedError *data.TrashedExternalIDError
+
+		switch {
+		case errors.As(err, &trashedError):
+			v.AddError("external_ids", fmt.Sprintf("an external id is already used by movie %s, which is in the trash and can be restored instead", trashedError.MovieID))
+			app.failedValidationResponse(w, r, v.Errors)
+		case errors.Is(err, data.ErrDuplicateExternalID):
+			v.AddError("external_ids", "an external id is already used by another movie")
+			app.failedValidationResponse(w, r, v.Errors)
+		case errors.Is(err, data.ErrFutureReleaseYear):
+			v.AddError("status", "cannot be released as the earliest release of the movie is in the future")
+			app.failedValidationResponse(w, r, v.Errors)
+		case errors.Is(err, data.ErrEditConflict) && r.Header.Get("If-Match") != "":
+			app.preconditionFailedResponse(w, r)
+		case errors.Is(err, data.ErrEditConflict):
+			app.editConflictResponse(w, r)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
 	}
 
-	if input.Runtime != nil {
-		movie.Runtime = *input.Runtime
+	err = app.embedMovieResources([]*data.Movie{movie}, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
 	}
 
-	if input.Genres != nil {
-		movie.Genres = input.Genres
+	err = app.localizeMovies(w, r, []*data.Movie{movie})
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	headers := make(http.Header)
+
+	err = setMovieValidators(headers, movie, movie)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	err = app.writeJSON(w, http.StatusOK, envelope{"movie": movie}, headers)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+// replaceMovieHandler replaces every field of a movie, which must all be provided just
+// like when creating one.
+func (app *application) replaceMovieHandler(w http.ResponseWriter, r *http.Request) {
+	id, err := app.readIDParams(r)
+	if err != nil {
+		app.notFoundResponse(w, r)
+		return
+	}
+
+	movie, err := app.models.Movies.Get(id)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrRecordNotFound):
+			app.notFoundResponse(w, r)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	if ifMatchFailed(r, movieVersionTag(movie)) {
+		app.preconditionFailedResponse(w, r)
+		return
+	}
+
+	var input struct {
+		Title       string            `json:"title"`
+		Year        int32             `json:"year"`
+		Runtime     data.Runtime      `json:"runtime"`
+		Genres      []string          `json:"genres"`
+		Status      string            `json:"status"`
+		ExternalIDs map[string]string `json:"external_ids"`
+	}
+
+	err = app.readJSON(w, r, &input)
+	if err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	if input.Status == "" {
+		input.Status = data.MovieStatusReleased
+	}
+
+	movie.Title = input.Title
+	movie.Year = input.Year
+	movie.Runtime = input.Runtime
+	movie.Genres = input.Genres
+	movie.Status = input.Status
+	movie.ExternalIDs = input.ExternalIDs
+
+	genres, err := app.models.Genres.Slugs()
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
 	}
 
 	v := validator.New()
 
-	if data.ValidateMovie(v, movie); !v.Valid() {
+	if data.ValidateMovie(v, movie, genres); !v.Valid() {
 		app.failedValidationResponse(w, r, v.Errors)
 		return
 	}
 
-	err = app.models.Movies.Update(movie)
+	err = app.models.Movies.Update(movie, app.contextGetUser(r).ID)
 	if err != nil {
+		var trashedError *data.TrashedExternalIDError
+
 		switch {
+		case errors.As(err, &trashedError):
+			v.AddError("external_ids", fmt.Sprintf("an external id is already used by movie %s, which is in the trash and can be restored instead", trashedError.MovieID))
+			app.failedValidationResponse(w, r, v.Errors)
+		case errors.Is(err, data.ErrDuplicateExternalID):
+			v.AddError("external_ids", "an external id is already used by another movie")
+			app.failedValidationResponse(w, r, v.Errors)
+		case errors.Is(err, data.ErrFutureReleaseYear):
+			v.AddError("status", "cannot be released as the earliest release of the movie is in the future")
+			app.failedValidationResponse(w, r, v.Errors)
+		case errors.Is(err, data.ErrEditConflict) && r.Header.Get("If-Match") != "":
+			app.preconditionFailedResponse(w, r)
 		case errors.Is(err, data.ErrEditConflict):
 			app.editConflictResponse(w, r)
 		default:
@@ -143,7 +411,27 @@ func (app *application) updateMovieHandler(w http.ResponseWriter, r *http.Reques
 		return
 	}
 
-	err = app.writeJSON(w, http.StatusOK, envelope{"movie": movie}, nil)
+	err = app.embedMovieResources([]*data.Movie{movie}, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	err = app.localizeMovies(w, r, []*data.Movie{movie})
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	headers := make(http.Header)
+
+	err = setMovieValidators(headers, movie, movie)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	err = app.writeJSON(w, http.StatusOK, envelope{"movie": movie}, headers)
 	if err != nil {
 		app.serverErrorResponse(w, r, err)
 	}
@@ -156,11 +444,37 @@ func (app *application) deleteMovieHandler(w http.ResponseWriter, r *http.Reques
 		return
 	}
 
-	err = app.models.Movies.Delete(id)
+	// Without an If-Match header the movie is deleted whatever its version. With one,
+	// it is only deleted while it is still at the version the client has seen.
+	var version int32
+
+	if r.Header.Get("If-Match") != "" {
+		movie, err := app.models.Movies.Get(id)
+		if err != nil {
+			switch {
+			case errors.Is(err, data.ErrRecordNotFound):
+				app.notFoundResponse(w, r)
+			default:
+				app.serverErrorResponse(w, r, err)
+			}
+			return
+		}
+
+		if ifMatchFailed(r, movieVersionTag(movie)) {
+			app.preconditionFailedResponse(w, r)
+			return
+		}
+
+		version = movie.Version
+	}
+
+	err = app.models.Movies.Delete(id, version)
 	if err != nil {
 		switch {
 		case errors.Is(err, data.ErrRecordNotFound):
 			app.notFoundResponse(w, r)
+		case errors.Is(err, data.ErrEditConflict):
+			app.preconditionFailedResponse(w, r)
 		default:
 			app.serverErrorResponse(w, r, err)
 		}
@@ -175,28 +489,180 @@ func (app *application) deleteMovieHandler(w http.ResponseWriter, r *http.Reques
 
 func (app *application) listMoviesHandler(w http.ResponseWriter, r *http.Request) {
 	var input struct {
-		Title  string
-		Genres []string
+		data.MovieFilters
 		data.Filters
+		Facets  []string
+		Fields  []string
+		Include []string
 	}
 
 	v := validator.New()
 
 	qs := r.URL.Query()
 
-	input.Title = app.readString(qs, "title", "")
-	input.Genres = app.readCSV(qs, "genres", []string{})
+	input.MovieFilters = app.readMovieFilters(qs, v)
+	input.Facets = app.readCSV(qs, "facets", []string{})
+	input.Fields, input.Include = app.readMovieFieldset(qs, v)
 	input.Filters.Page = app.readInt(qs, "page", 1, v)
 	input.Filters.PageSize = app.readInt(qs, "page_size", 20, v)
 	input.Filters.Sort = app.readString(qs, "sort", "id")
-	input.Filters.SortSafelist = []string{"id", "title", "year", "runtime", "-id", "-title", "-year", "-runtime"}
+	input.Filters.SortSafelist = movieSortSafelist
+
+	if qs.Has("cursor") {
+		input.Filters.Cursor = app.readCursor(qs, "cursor", v)
+	}
+
+	data.ValidateMovieSort(v, input.MovieFilters, input.Filters)
+
+	for _, facet := range input.Facets {
+		v.Check(validator.In(facet, data.MovieFacets...), "facets", "invalid facets value")
+	}
+
+	if data.ValidateFilters(v, input.Filters); !v.Valid() {
+		app.failedValidationResponse(w, r, v.Errors)
+		return
+	}
+
+	movies, metadata, err := app.models.Movies.GetAll(input.MovieFilters, input.Filters)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	err = app.embedMovieResources(movies, input.Include)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	err = app.localizeMovies(w, r, movies)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	trimmed, err := trimMovies(movies, input.Fields, input.Include)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	env := envelope{"movies": trimmed, "metadata": metadata}
+
+	if len(input.Facets) > 0 {
+		env["facets"], err = app.models.Movies.GetFacets(input.MovieFilters, input.Facets)
+		if err != nil {
+			app.serverErrorResponse(w, r, err)
+			return
+		}
+	}
+
+	err = app.writeJSON(w, http.StatusOK, env, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+// suggestMoviesHandler returns a handful of movies matching a partially typed title. It
+// is meant to be called on every keystroke, so it only returns enough of each movie to
+// show in a dropdown.
+func (app *application) suggestMoviesHandler(w http.ResponseWriter, r *http.Request) {
+	v := validator.New()
+
+	qs := r.URL.Query()
+
+	q := strings.TrimSpace(app.readString(qs, "q", ""))
+	limit := app.readInt(qs, "limit", 10, v)
+
+	if data.ValidateSuggestQuery(v, q, limit); !v.Valid() {
+		app.failedValidationResponse(w, r, v.Errors)
+		return
+	}
+
+	suggestions, err := app.models.Movies.Suggest(q, limit)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	err = app.writeJSON(w, http.StatusOK, envelope{"suggestions": suggestions}, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+// showExternalMovieHandler looks a movie up by its identifier from an external source,
+// such as an IMDb "tt" id.
+func (app *application) showExternalMovieHandler(w http.ResponseWriter, r *http.Request) {
+	params := httprouter.ParamsFromContext(r.Context())
+
+	source, externalID := params.ByName("source"), params.ByName("id")
+
+	v := validator.New()
+
+	if data.ValidateExternalID(v, source, externalID); !v.Valid() {
+		app.failedValidationResponse(w, r, v.Errors)
+		return
+	}
+
+	movie, err := app.models.Movies.GetByExternalID(source, externalID)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrRecordNotFound):
+			app.notFoundResponse(w, r)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	err = app.embedMovieResources([]*data.Movie{movie}, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	err = app.localizeMovies(w, r, []*data.Movie{movie})
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	headers := make(http.Header)
+	headers.Set("Content-Location", fmt.Sprintf("/v1/movies/%s", movie.ID))
+
+	err = setMovieValidators(headers, movie, movie)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	err = app.writeJSON(w, http.StatusOK, envelope{"movie": movie}, headers)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+func (app *application) listTrashHandler(w http.ResponseWriter, r *http.Request) {
+	var input struct {
+		data.Filters
+	}
+
+	v := validator.New()
+
+	qs := r.URL.Query()
+
+	input.Filters.Page = app.readInt(qs, "page", 1, v)
+	input.Filters.PageSize = app.readInt(qs, "page_size", 20, v)
+	input.Filters.Sort = app.readString(qs, "sort", "-deleted_at")
+	input.Filters.SortSafelist = []string{"id", "title", "deleted_at", "-id", "-title", "-deleted_at"}
 
 	if data.ValidateFilters(v, input.Filters); !v.Valid() {
 		app.failedValidationResponse(w, r, v.Errors)
 		return
 	}
 
-	movies, metadata, err := app.models.Movies.GetAll(input.Title, input.Genres, input.Filters)
+	movies, metadata, err := app.models.Movies.GetAllDeleted(input.Filters)
 	if err != nil {
 		app.serverErrorResponse(w, r, err)
 		return
@@ -207,3 +673,206 @@ func (app *application) listMoviesHandler(w http.ResponseWriter, r *http.Request
 		app.serverErrorResponse(w, r, err)
 	}
 }
+
+// upcomingMoviesHandler lists the movies which are yet to be released, by default the
+// soonest expected first.
+func (app *application) upcomingMoviesHandler(w http.ResponseWriter, r *http.Request) {
+	var input struct {
+		Fields  []string
+		Include []string
+		data.Filters
+	}
+
+	v := validator.New()
+
+	qs := r.URL.Query()
+
+	input.Fields, input.Include = app.readMovieFieldset(qs, v)
+
+	input.Filters.Page = app.readInt(qs, "page", 1, v)
+	input.Filters.PageSize = app.readInt(qs, "page_size", 20, v)
+	input.Filters.Sort = app.readString(qs, "sort", "expected_release")
+	input.Filters.SortSafelist = []string{"expected_release", "-expected_release"}
+
+	if data.ValidateFilters(v, input.Filters); !v.Valid() {
+		app.failedValidationResponse(w, r, v.Errors)
+		return
+	}
+
+	movies, metadata, err := app.models.Movies.GetUpcoming(input.Filters)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	err = app.embedMovieResources(movies, input.Include)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	err = app.localizeMovies(w, r, movies)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	trimmed, err := trimMovies(movies, input.Fields, input.Include)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	err = app.writeJSON(w, http.StatusOK, envelope{"movies": trimmed, "metadata": metadata}, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+func (app *application) restoreMovieHandler(w http.ResponseWriter, r *http.Request) {
+	id, err := app.readIDParams(r)
+	if err != nil {
+		app.notFoundResponse(w, r)
+		return
+	}
+
+	err = app.models.Movies.Restore(id, app.contextGetUser(r).ID)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrRecordNotFound):
+			app.notFoundResponse(w, r)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	movie, err := app.models.Movies.Get(id)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	err = app.writeJSON(w, http.StatusOK, envelope{"movie": movie}, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+// mergeMovieHandler merges a duplicate movie into the movie given in the request body,
+// which survives it, and responds with the surviving movie.
+func (app *application) mergeMovieHandler(w http.ResponseWriter, r *http.Request) {
+	id, err := app.readIDParams(r)
+	if err != nil {
+		app.notFoundResponse(w, r)
+		return
+	}
+
+	var input struct {
+		Into uuid.UUID `json:"into"`
+	}
+
+	err = app.readJSON(w, r, &input)
+	if err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	v := validator.New()
+
+	v.Check(input.Into != uuid.Nil, "into", "must be provided")
+	v.Check(input.Into != id, "into", "must be a different movie")
+
+	if !v.Valid() {
+		app.failedValidationResponse(w, r, v.Errors)
+		return
+	}
+
+	images, err := app.models.Images.GetAllForMovies([]uuid.UUID{id, input.Into})
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	err = app.models.Movies.Merge(id, input.Into, app.contextGetUser(r).ID)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrRecordNotFound):
+			app.notFoundResponse(w, r)
+		case errors.Is(err, data.ErrFutureReleaseYear):
+			v.AddError("into", "must not be a released movie, as the earliest release of the merged movies is in the future")
+			app.failedValidationResponse(w, r, v.Errors)
+		case errors.Is(err, data.ErrRelationCycle):
+			v.AddError("into", "must not be related to the merged movie through other movies, as merging would form a cycle of relations")
+			app.failedValidationResponse(w, r, v.Errors)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	// The images of the merged movie were moved over, apart from those of the kinds
+	// which the surviving movie already had, which were deleted with it.
+	for kind, img := range images[id] {
+		if images[input.Into][kind] != nil {
+			app.deleteImageFiles(img)
+		}
+	}
+
+	movie, err := app.models.Movies.Get(input.Into)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	err = app.embedMovieResources([]*data.Movie{movie}, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	err = app.localizeMovies(w, r, []*data.Movie{movie})
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	headers := make(http.Header)
+
+	err = setMovieValidators(headers, movie, movie)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	err = app.writeJSON(w, http.StatusOK, envelope{"movie": movie}, headers)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+// redirectMergedMovie sends a 301 Moved Permanently response pointing a request for a
+// movie which has been merged away at the movie it was merged into, or a 404 Not Found
+// response if there is no such movie.
+func (app *application) redirectMergedMovie(w http.ResponseWriter, r *http.Request, id uuid.UUID) {
+	movieID, err := app.models.Movies.GetRedirect(id)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrRecordNotFound):
+			app.notFoundResponse(w, r)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	location := url.URL{Path: "/v1/movies/" + movieID.String(), RawQuery: r.URL.RawQuery}
+
+	headers := make(http.Header)
+	headers.Set("Location", location.String())
+
+	err = app.writeJSON(w, http.StatusMovedPermanently, envelope{"location": location.String()}, headers)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
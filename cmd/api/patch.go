@@ -0,0 +1,150 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+
+	jsonpatch "github.com/evanphx/json-patch/v5"
+	"github.com/petrostrak/gomdb/internal/data"
+)
+
+const (
+	mergePatchContentType = "application/merge-patch+json"
+	jsonPatchContentType  = "application/json-patch+json"
+)
+
+// movieDocument is the JSON document which patches are applied to. It holds the
+// fields of a movie which clients are allowed to change.
+type movieDocument struct {
+	Title       string            `json:"title"`
+	Year        int32             `json:"year"`
+	Runtime     data.Runtime      `json:"runtime"`
+	Genres      []string          `json:"genres"`
+	Status      string            `json:"status"`
+	ExternalIDs map[string]string `json:"external_ids"`
+}
+
+// readMovieUpdate applies a partial update, where any field left out of the request
+// body keeps its current value, to the movie. External ids are updated per source, and
+// a null id removes the movie's id from that source.
+func (app *application) readMovieUpdate(w http.ResponseWriter, r *http.Request, movie *data.Movie) error {
+	var input struct {
+		Title       *string            `json:"title"`
+		Year        *int32             `json:"year"`
+		Runtime     *data.Runtime      `json:"runtime"`
+		Genres      []string           `json:"genres"`
+		Status      *string            `json:"status"`
+		ExternalIDs map[string]*string `json:"external_ids"`
+	}
+
+	err := app.readJSON(w, r, &input)
+	if err != nil {
+		return err
+	}
+
+	if input.Title != nil {
+		movie.Title = *input.Title
+	}
+
+	if input.Year != nil {
+		movie.Year = *input.Year
+	}
+
+	if input.Runtime != nil {
+		movie.Runtime = *input.Runtime
+	}
+
+	if input.Genres != nil {
+		movie.Genres = input.Genres
+	}
+
+	if input.Status != nil {
+		movie.Status = *input.Status
+	}
+
+	for source, id := range input.ExternalIDs {
+		if movie.ExternalIDs == nil {
+			movie.ExternalIDs = make(map[string]string)
+		}
+
+		if id == nil {
+			delete(movie.ExternalIDs, source)
+		} else {
+			movie.ExternalIDs[source] = *id
+		}
+	}
+
+	return nil
+}
+
+// readMoviePatch applies a JSON Merge Patch (RFC 7396) or a JSON Patch (RFC 6902) from
+// the request body to the movie. Unlike readMovieUpdate, a patch can clear a field or
+// change part of an array. A JSON Patch whose test operation fails returns an error
+// wrapping jsonpatch.ErrTestFailed.
+func (app *application) readMoviePatch(w http.ResponseWriter, r *http.Request, contentType string, movie *data.Movie) error {
+	var patch json.RawMessage
+
+	err := app.readJSON(w, r, &patch)
+	if err != nil {
+		return err
+	}
+
+	doc, err := json.Marshal(movieDocument{
+		Title:       movie.Title,
+		Year:        movie.Year,
+		Runtime:     movie.Runtime,
+		Genres:      movie.Genres,
+		Status:      movie.Status,
+		ExternalIDs: movie.ExternalIDs,
+	})
+	if err != nil {
+		return err
+	}
+
+	switch contentType {
+	case mergePatchContentType:
+		doc, err = jsonpatch.MergePatch(doc, patch)
+	default:
+		var operations jsonpatch.Patch
+
+		operations, err = jsonpatch.DecodePatch(patch)
+		if err == nil {
+			doc, err = operations.Apply(doc)
+		}
+	}
+	if err != nil {
+		return fmt.Errorf("patch could not be applied: %w", err)
+	}
+
+	var patched movieDocument
+
+	dec := json.NewDecoder(bytes.NewReader(doc))
+	dec.DisallowUnknownFields()
+
+	err = dec.Decode(&patched)
+	if err != nil {
+		var unmarshalTypeError *json.UnmarshalTypeError
+
+		switch {
+		case errors.As(err, &unmarshalTypeError) && unmarshalTypeError.Field != "":
+			return fmt.Errorf("patch results in incorrect JSON type for field %q", unmarshalTypeError.Field)
+		case strings.HasPrefix(err.Error(), "json: unknown field "):
+			return fmt.Errorf("patch results in unknown key %s", strings.TrimPrefix(err.Error(), "json: unknown field "))
+		default:
+			return fmt.Errorf("patch results in an invalid movie: %w", err)
+		}
+	}
+
+	movie.Title = patched.Title
+	movie.Year = patched.Year
+	movie.Runtime = patched.Runtime
+	movie.Genres = patched.Genres
+	movie.Status = patched.Status
+	movie.ExternalIDs = patched.ExternalIDs
+
+	return nil
+}
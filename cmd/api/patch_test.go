@@ -0,0 +1,57 @@
+package main
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"strings"
+	"testing"
+
+	jsonpatch "github.com/evanphx/json-patch/v5"
+	"github.com/petrostrak/gomdb/internal/data"
+)
+
+func Test_readMoviePatch(t *testing.T) {
+	tests := []struct {
+		name        string
+		contentType string
+		body        string
+		genres      []string
+		year        int32
+		err         error
+	}{
+		{"merge patch", mergePatchContentType, `{"year": 1980, "genres": ["horror"]}`, []string{"horror"}, 1980, nil},
+		{"merge patch clearing a field", mergePatchContentType, `{"year": null}`, []string{"horror", "sci-fi"}, 0, nil},
+		{"json patch", jsonPatchContentType, `[{"op": "add", "path": "/genres/-", "value": "thriller"}]`, []string{"horror", "sci-fi", "thriller"}, 1979, nil},
+		{"failed test", jsonPatchContentType, `[{"op": "test", "path": "/year", "value": 1980}]`, nil, 0, jsonpatch.ErrTestFailed},
+	}
+
+	for _, tt := range tests {
+		movie := &data.Movie{Title: "Alien", Year: 1979, Runtime: 117, Genres: []string{"horror", "sci-fi"}}
+
+		r := httptest.NewRequest(http.MethodPatch, "/", strings.NewReader(tt.body))
+		err := app.readMoviePatch(httptest.NewRecorder(), r, tt.contentType, movie)
+
+		if tt.err != nil {
+			if !errors.Is(err, tt.err) {
+				t.Errorf("%s: expected %v but got %v", tt.name, tt.err, err)
+			}
+			continue
+		}
+
+		if err != nil {
+			t.Fatalf("%s: unexpected error: %s", tt.name, err)
+		}
+
+		if movie.Year != tt.year || !slices.Equal(movie.Genres, tt.genres) || movie.Title != "Alien" {
+			t.Errorf("%s: unexpected result %+v", tt.name, movie)
+		}
+	}
+
+	r := httptest.NewRequest(http.MethodPatch, "/", strings.NewReader(`{"rating": 5}`))
+	err := app.readMoviePatch(httptest.NewRecorder(), r, mergePatchContentType, &data.Movie{})
+	if err == nil {
+		t.Error("expected an error for an unknown field")
+	}
+}
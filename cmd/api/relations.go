@@ -0,0 +1,175 @@
+package main
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/google/uuid"
+	"github.com/julienschmidt/httprouter"
+	"github.com/petrostrak/gomdb/internal/data"
+	"github.com/petrostrak/gomdb/internal/validator"
+)
+
+func (app *application) createRelationHandler(w http.ResponseWriter, r *http.Request) {
+	id, err := app.readIDParams(r)
+	if err != nil {
+		app.notFoundResponse(w, r)
+		return
+	}
+
+	movie, err := app.models.Movies.Get(id)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrRecordNotFound):
+			app.notFoundResponse(w, r)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	var input struct {
+		RelatedMovieID uuid.UUID `json:"related_movie_id"`
+		Type           string    `json:"type"`
+	}
+
+	err = app.readJSON(w, r, &input)
+	if err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	relation := &data.Relation{
+		MovieID:        movie.ID,
+		RelatedMovieID: input.RelatedMovieID,
+		Type:           input.Type,
+	}
+
+	v := validator.New()
+
+	if data.ValidateRelation(v, relation); !v.Valid() {
+		app.failedValidationResponse(w, r, v.Errors)
+		return
+	}
+
+	_, err = app.models.Movies.Get(relation.RelatedMovieID)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrRecordNotFound):
+			v.AddError("related_movie_id", "must reference an existing movie")
+			app.failedValidationResponse(w, r, v.Errors)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	err = app.models.Relations.Insert(relation)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrDuplicateRelation):
+			v.AddError("related_movie_id", "the movie already has this relation")
+			app.failedValidationResponse(w, r, v.Errors)
+		case errors.Is(err, data.ErrRelationCycle):
+			v.AddError("related_movie_id", "must not be related to the movie already, as this would form a cycle of relations")
+			app.failedValidationResponse(w, r, v.Errors)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	err = app.writeJSON(w, http.StatusCreated, envelope{"relation": relation}, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+// listRelatedMoviesHandler returns the movies related to a movie, optionally only
+// through relations of one type, following chains of relations up to the requested
+// depth.
+func (app *application) listRelatedMoviesHandler(w http.ResponseWriter, r *http.Request) {
+	id, err := app.readIDParams(r)
+	if err != nil {
+		app.notFoundResponse(w, r)
+		return
+	}
+
+	var input struct {
+		Type  string
+		Depth int
+	}
+
+	v := validator.New()
+
+	qs := r.URL.Query()
+
+	input.Type = app.readString(qs, "type", "")
+	input.Depth = app.readInt(qs, "depth", 1, v)
+
+	if input.Type != "" {
+		v.Check(validator.In(input.Type, data.RelationTypes...), "type", "must be one of sequel_of, remake_of, spin_off_of or based_on")
+	}
+
+	v.Check(input.Depth >= 1, "depth", "must be greater than zero")
+	v.Check(input.Depth <= 10, "depth", "must be a maximum of 10")
+
+	if !v.Valid() {
+		app.failedValidationResponse(w, r, v.Errors)
+		return
+	}
+
+	movie, err := app.models.Movies.Get(id)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrRecordNotFound):
+			app.notFoundResponse(w, r)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	related, err := app.models.Relations.GetRelated(movie.ID, input.Type, input.Depth)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	err = app.writeJSON(w, http.StatusOK, envelope{"related": related}, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+func (app *application) deleteRelationHandler(w http.ResponseWriter, r *http.Request) {
+	id, err := app.readIDParams(r)
+	if err != nil {
+		app.notFoundResponse(w, r)
+		return
+	}
+
+	relatedMovieID, err := app.readUUIDParam(r, "related_id")
+	if err != nil {
+		app.notFoundResponse(w, r)
+		return
+	}
+
+	relationType := httprouter.ParamsFromContext(r.Context()).ByName("type")
+
+	err = app.models.Relations.Delete(id, relatedMovieID, relationType)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrRecordNotFound):
+			app.notFoundResponse(w, r)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	err = app.writeJSON(w, http.StatusOK, envelope{"message": "successfully deleted"}, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
@@ -15,18 +15,121 @@ func (app *application) routes() http.Handler {
 
 	router.HandlerFunc(http.MethodGet, "/v1/healthcheck", app.healthcheckHandler)
 
-	router.HandlerFunc(http.MethodPost, "/v1/movies", app.requirePermission("movies:write", app.createMovieHandler))
+	router.HandlerFunc(http.MethodPost, "/v1/movies", app.requirePermission("movies:write", app.idempotent(app.createMovieHandler)))
 	router.HandlerFunc(http.MethodGet, "/v1/movies/:id", app.requirePermission("movies:read", app.showMovieHandler))
+	router.HandlerFunc(http.MethodPut, "/v1/movies/:id", app.requirePermission("movies:write", app.replaceMovieHandler))
 	router.HandlerFunc(http.MethodPatch, "/v1/movies/:id", app.requirePermission("movies:write", app.updateMovieHandler))
 	router.HandlerFunc(http.MethodDelete, "/v1/movies/:id", app.requirePermission("movies:write", app.deleteMovieHandler))
 	router.HandlerFunc(http.MethodGet, "/v1/movies", app.requirePermission("movies:read", app.listMoviesHandler))
 
-	router.HandlerFunc(http.MethodPost, "/v1/users", app.registerUserHandler)
+	router.HandlerFunc(http.MethodPost, "/v1/movies/:id/restore", app.requirePermission("movies:admin", app.idempotent(app.restoreMovieHandler)))
+	router.HandlerFunc(http.MethodPost, "/v1/movies/:id/merge", app.requirePermission("movies:admin", app.idempotent(app.mergeMovieHandler)))
+
+	// Image uploads aren't idempotent, as the middleware only buffers bodies of up to
+	// 1MB; being a PUT, retrying one just replaces the image with the same one again.
+	router.HandlerFunc(http.MethodPut, "/v1/movies/:id/images/:kind", app.requirePermission("movies:write", app.uploadMovieImageHandler))
+	router.HandlerFunc(http.MethodDelete, "/v1/movies/:id/images/:kind", app.requirePermission("movies:write", app.deleteMovieImageHandler))
+
+	router.HandlerFunc(http.MethodGet, "/v1/movies/:id/revisions", app.requirePermission("movies:read", app.listRevisionsHandler))
+	router.HandlerFunc(http.MethodPost, "/v1/movies/:id/revisions/:version/revert", app.requirePermission("movies:write", app.idempotent(app.revertMovieHandler)))
+
+	router.HandlerFunc(http.MethodGet, "/v1/movies/:id/credits", app.requirePermission("movies:read", app.listCreditsHandler))
+	router.HandlerFunc(http.MethodPost, "/v1/movies/:id/credits", app.requirePermission("movies:write", app.idempotent(app.createCreditHandler)))
+	router.HandlerFunc(http.MethodDelete, "/v1/movies/:id/credits/:credit_id", app.requirePermission("movies:write", app.deleteCreditHandler))
+
+	router.HandlerFunc(http.MethodGet, "/v1/movies/:id/titles", app.requirePermission("movies:read", app.listTitlesHandler))
+	router.HandlerFunc(http.MethodPost, "/v1/movies/:id/titles", app.requirePermission("movies:write", app.idempotent(app.createTitleHandler)))
+	router.HandlerFunc(http.MethodDelete, "/v1/movies/:id/titles/:title_id", app.requirePermission("movies:write", app.deleteTitleHandler))
+
+	router.HandlerFunc(http.MethodGet, "/v1/movies/:id/releases", app.requirePermission("movies:read", app.listReleasesHandler))
+	router.HandlerFunc(http.MethodPost, "/v1/movies/:id/releases", app.requirePermission("movies:write", app.idempotent(app.createReleaseHandler)))
+	router.HandlerFunc(http.MethodDelete, "/v1/movies/:id/releases/:release_id", app.requirePermission("movies:write", app.deleteReleaseHandler))
+
+	router.HandlerFunc(http.MethodGet, "/v1/movies/:id/certifications", app.requirePermission("movies:read", app.listCertificationsHandler))
+	router.HandlerFunc(http.MethodPut, "/v1/movies/:id/certifications/:country", app.requirePermission("movies:write", app.setCertificationHandler))
+	router.HandlerFunc(http.MethodDelete, "/v1/movies/:id/certifications/:country", app.requirePermission("movies:write", app.deleteCertificationHandler))
+
+	router.HandlerFunc(http.MethodGet, "/v1/movies/:id/related", app.requirePermission("movies:read", app.listRelatedMoviesHandler))
+	router.HandlerFunc(http.MethodPost, "/v1/movies/:id/related", app.requirePermission("movies:write", app.idempotent(app.createRelationHandler)))
+	router.HandlerFunc(http.MethodDelete, "/v1/movies/:id/related/:type/:related_id", app.requirePermission("movies:write", app.deleteRelationHandler))
+
+	router.HandlerFunc(http.MethodGet, "/v1/movies/:id/reviews", app.requirePermission("movies:read", app.listReviewsHandler))
+	router.HandlerFunc(http.MethodPost, "/v1/movies/:id/reviews", app.requirePermission("movies:read", app.idempotent(app.createReviewHandler)))
+	router.HandlerFunc(http.MethodPatch, "/v1/movies/:id/reviews", app.requirePermission("movies:read", app.updateReviewHandler))
+	router.HandlerFunc(http.MethodDelete, "/v1/movies/:id/reviews", app.requirePermission("movies:read", app.deleteReviewHandler))
+
+	router.HandlerFunc(http.MethodGet, "/v1/people", app.requirePermission("movies:read", app.listPeopleHandler))
+	router.HandlerFunc(http.MethodPost, "/v1/people", app.requirePermission("movies:write", app.idempotent(app.createPersonHandler)))
+	router.HandlerFunc(http.MethodGet, "/v1/people/:id", app.requirePermission("movies:read", app.showPersonHandler))
+	router.HandlerFunc(http.MethodPatch, "/v1/people/:id", app.requirePermission("movies:write", app.updatePersonHandler))
+	router.HandlerFunc(http.MethodDelete, "/v1/people/:id", app.requirePermission("movies:write", app.deletePersonHandler))
+
+	router.HandlerFunc(http.MethodGet, "/v1/collections", app.requirePermission("movies:read", app.listCollectionsHandler))
+	router.HandlerFunc(http.MethodPost, "/v1/collections", app.requirePermission("movies:write", app.idempotent(app.createCollectionHandler)))
+	router.HandlerFunc(http.MethodGet, "/v1/collections/:id", app.requirePermission("movies:read", app.showCollectionHandler))
+	router.HandlerFunc(http.MethodPatch, "/v1/collections/:id", app.requirePermission("movies:write", app.updateCollectionHandler))
+	router.HandlerFunc(http.MethodDelete, "/v1/collections/:id", app.requirePermission("movies:write", app.deleteCollectionHandler))
+	router.HandlerFunc(http.MethodGet, "/v1/collections/:id/movies", app.requirePermission("movies:read", app.listCollectionMoviesHandler))
+	router.HandlerFunc(http.MethodPost, "/v1/collections/:id/movies", app.requirePermission("movies:write", app.idempotent(app.addCollectionMovieHandler)))
+	router.HandlerFunc(http.MethodPatch, "/v1/collections/:id/movies/:movie_id", app.requirePermission("movies:write", app.moveCollectionMovieHandler))
+	router.HandlerFunc(http.MethodDelete, "/v1/collections/:id/movies/:movie_id", app.requirePermission("movies:write", app.removeCollectionMovieHandler))
+
+	router.HandlerFunc(http.MethodGet, "/v1/genres", app.requirePermission("movies:read", app.listGenresHandler))
+	router.HandlerFunc(http.MethodPost, "/v1/genres", app.requirePermission("movies:admin", app.idempotent(app.createGenreHandler)))
+	router.HandlerFunc(http.MethodGet, "/v1/genres/:slug", app.requirePermission("movies:read", app.showGenreHandler))
+	router.HandlerFunc(http.MethodPatch, "/v1/genres/:slug", app.requirePermission("movies:admin", app.updateGenreHandler))
+	router.HandlerFunc(http.MethodPost, "/v1/genres/:slug/merge", app.requirePermission("movies:admin", app.idempotent(app.mergeGenreHandler)))
+
+	router.HandlerFunc(http.MethodPost, "/v1/users", app.idempotent(app.registerUserHandler))
 	router.HandlerFunc(http.MethodPut, "/v1/users/activated", app.activateUserHandler)
 
+	router.HandlerFunc(http.MethodGet, "/v1/users/me/lists", app.requireActivatedUser(app.listUserListsHandler))
+	router.HandlerFunc(http.MethodPost, "/v1/users/me/lists", app.requireActivatedUser(app.idempotent(app.createListHandler)))
+	router.HandlerFunc(http.MethodGet, "/v1/users/me/lists/:list_id", app.requireActivatedUser(app.showUserListHandler))
+	router.HandlerFunc(http.MethodPatch, "/v1/users/me/lists/:list_id", app.requireActivatedUser(app.updateListHandler))
+	router.HandlerFunc(http.MethodDelete, "/v1/users/me/lists/:list_id", app.requireActivatedUser(app.deleteListHandler))
+	router.HandlerFunc(http.MethodPost, "/v1/users/me/lists/:list_id/items", app.requireActivatedUser(app.idempotent(app.addListItemHandler)))
+	router.HandlerFunc(http.MethodPatch, "/v1/users/me/lists/:list_id/items/:movie_id", app.requireActivatedUser(app.moveListItemHandler))
+	router.HandlerFunc(http.MethodDelete, "/v1/users/me/lists/:list_id/items/:movie_id", app.requireActivatedUser(app.removeListItemHandler))
+
+	router.HandlerFunc(http.MethodGet, "/v1/lists/:id", app.requirePermission("movies:read", app.showPublicListHandler))
+
 	router.HandlerFunc(http.MethodPost, "/v1/tokens/authentication", app.createAuthenticationTokenHandler)
 
+	// Files kept in local storage are served by the API itself.
+	if files, ok := app.storage.(http.Handler); ok {
+		router.Handler(http.MethodGet, "/v1/images/*key", http.StripPrefix("/v1/images", files))
+	}
+
 	router.Handler(http.MethodGet, "/debug/vars", expvar.Handler())
 
-	return app.metrics(app.recoverPanic(app.enableCORS(app.rateLimit(app.authenticate(router)))))
+	// httprouter doesn't allow a static path segment to share its position with a
+	// wildcard, so collection-level routes such as /v1/movies/trash, which would clash
+	// with /v1/movies/:id, are registered on a separate router that is tried first.
+	collections := httprouter.New()
+
+	collections.HandlerFunc(http.MethodGet, "/v1/movies/trash", app.requirePermission("movies:admin", app.listTrashHandler))
+	collections.HandlerFunc(http.MethodGet, "/v1/movies/suggest", app.requirePermission("movies:read", app.suggestMoviesHandler))
+	collections.HandlerFunc(http.MethodGet, "/v1/movies/upcoming", app.requirePermission("movies:read", app.upcomingMoviesHandler))
+	collections.HandlerFunc(http.MethodGet, "/v1/movies/export", app.requirePermission("movies:export", app.exportMoviesHandler))
+	// Imports aren't idempotent either, as their files are far larger than the bodies
+	// the middleware buffers. Clients can check what a retry would do with ?dry_run=true,
+	// and atomic imports, the default, save nothing unless every row is saved.
+	collections.HandlerFunc(http.MethodPost, "/v1/movies/import", app.requirePermission("movies:write", app.importMoviesHandler))
+	collections.HandlerFunc(http.MethodGet, "/v1/movies/external/:source/:id", app.requirePermission("movies:read", app.showExternalMovieHandler))
+
+	return app.metrics(app.recoverPanic(app.enableCORS(app.rateLimit(app.authenticate(dispatch(collections, router))))))
+}
+
+// dispatch serves the request with the primary router if it has a handler registered
+// for the method and path, and falls back to the secondary router otherwise.
+func dispatch(primary, secondary *httprouter.Router) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if handle, _, _ := primary.Lookup(r.Method, r.URL.Path); handle != nil {
+			primary.ServeHTTP(w, r)
+			return
+		}
+
+		secondary.ServeHTTP(w, r)
+	})
 }
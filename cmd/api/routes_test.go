@@ -0,0 +1,47 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/julienschmidt/httprouter"
+)
+
+func Test_dispatch(t *testing.T) {
+	primary := httprouter.New()
+	primary.HandlerFunc(http.MethodGet, "/v1/movies/trash", func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("primary"))
+	})
+
+	secondary := httprouter.New()
+	secondary.HandlerFunc(http.MethodGet, "/v1/movies/:id", func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("secondary"))
+	})
+
+	tests := []struct {
+		method   string
+		path     string
+		status   int
+		expected string
+	}{
+		{http.MethodGet, "/v1/movies/trash", http.StatusOK, "primary"},
+		{http.MethodGet, "/v1/movies/121f03cd-ce8c-447d-8747-fb8cb7aa3a52", http.StatusOK, "secondary"},
+		{http.MethodPost, "/v1/movies/trash", http.StatusMethodNotAllowed, ""},
+	}
+
+	handler := dispatch(primary, secondary)
+
+	for _, tt := range tests {
+		rr := httptest.NewRecorder()
+		handler.ServeHTTP(rr, httptest.NewRequest(tt.method, tt.path, nil))
+
+		if rr.Code != tt.status {
+			t.Errorf("%s %s: expected status %d but got %d", tt.method, tt.path, tt.status, rr.Code)
+		}
+
+		if tt.expected != "" && rr.Body.String() != tt.expected {
+			t.Errorf("%s %s: expected %q but got %q", tt.method, tt.path, tt.expected, rr.Body.String())
+		}
+	}
+}
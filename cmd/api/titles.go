@@ -0,0 +1,215 @@
+package main
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/google/uuid"
+	"github.com/petrostrak/gomdb/internal/data"
+	"github.com/petrostrak/gomdb/internal/validator"
+	"golang.org/x/text/language"
+)
+
+// localizedTitle picks the title to show to a client who prefers the given languages,
+// out of the title a movie is stored with and its translated and original alternate
+// titles, and returns it together with the movie's original title. An alternate title
+// is only picked when it is in one of the preferred languages.
+func localizedTitle(title string, titles []*data.AlternateTitle, preferred []language.Tag) (string, string) {
+	original := title
+
+	// The stored title comes first, as the matcher falls back to the first tag.
+	candidates := []string{title}
+	tags := []language.Tag{language.Und}
+
+	for _, t := range titles {
+		switch t.Type {
+		case data.TitleTypeOriginal:
+			original = t.Title
+		case data.TitleTypeWorking:
+			continue
+		}
+
+		s := t.Language
+		if t.Region != "" {
+			s += "-" + t.Region
+		}
+
+		tag, err := language.Parse(s)
+		if err != nil {
+			continue
+		}
+
+		candidates = append(candidates, t.Title)
+		tags = append(tags, tag)
+	}
+
+	if len(preferred) == 0 || len(tags) == 1 {
+		return title, original
+	}
+
+	_, index, confidence := language.NewMatcher(tags).Match(preferred...)
+	if confidence == language.No {
+		return title, original
+	}
+
+	return candidates[index], original
+}
+
+// localizeMovies replaces the title of every movie with the one in the language the
+// client prefers, according to its Accept-Language header, and sets their original
+// titles.
+func (app *application) localizeMovies(w http.ResponseWriter, r *http.Request, movies []*data.Movie) error {
+	w.Header().Add("Vary", "Accept-Language")
+
+	if len(movies) == 0 {
+		return nil
+	}
+
+	// A malformed header is ignored, just like a missing one.
+	preferred, _, _ := language.ParseAcceptLanguage(r.Header.Get("Accept-Language"))
+
+	ids := make([]uuid.UUID, len(movies))
+	for i, movie := range movies {
+		ids[i] = movie.ID
+	}
+
+	titles, err := app.models.Titles.GetAllForMovies(ids)
+	if err != nil {
+		return err
+	}
+
+	for _, movie := range movies {
+		movie.Title, movie.OriginalTitle = localizedTitle(movie.Title, titles[movie.ID], preferred)
+	}
+
+	return nil
+}
+
+func (app *application) createTitleHandler(w http.ResponseWriter, r *http.Request) {
+	id, err := app.readIDParams(r)
+	if err != nil {
+		app.notFoundResponse(w, r)
+		return
+	}
+
+	movie, err := app.models.Movies.Get(id)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrRecordNotFound):
+			app.notFoundResponse(w, r)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	var input struct {
+		Title    string `json:"title"`
+		Language string `json:"language"`
+		Region   string `json:"region"`
+		Type     string `json:"type"`
+	}
+
+	err = app.readJSON(w, r, &input)
+	if err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	title := &data.AlternateTitle{
+		MovieID:  movie.ID,
+		Title:    input.Title,
+		Language: input.Language,
+		Region:   input.Region,
+		Type:     input.Type,
+	}
+
+	v := validator.New()
+
+	if data.ValidateAlternateTitle(v, title); !v.Valid() {
+		app.failedValidationResponse(w, r, v.Errors)
+		return
+	}
+
+	err = app.models.Titles.Insert(title, app.contextGetUser(r).ID)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrDuplicateTitle):
+			v.AddError("title", "the movie already has this title")
+			app.failedValidationResponse(w, r, v.Errors)
+		case errors.Is(err, data.ErrDuplicateOriginalTitle):
+			v.AddError("type", "the movie already has an original title")
+			app.failedValidationResponse(w, r, v.Errors)
+		case errors.Is(err, data.ErrRecordNotFound):
+			app.notFoundResponse(w, r)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	err = app.writeJSON(w, http.StatusCreated, envelope{"title": title}, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+func (app *application) listTitlesHandler(w http.ResponseWriter, r *http.Request) {
+	id, err := app.readIDParams(r)
+	if err != nil {
+		app.notFoundResponse(w, r)
+		return
+	}
+
+	movie, err := app.models.Movies.Get(id)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrRecordNotFound):
+			app.notFoundResponse(w, r)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	titles, err := app.models.Titles.GetAllForMovie(movie.ID)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	err = app.writeJSON(w, http.StatusOK, envelope{"titles": titles}, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+func (app *application) deleteTitleHandler(w http.ResponseWriter, r *http.Request) {
+	id, err := app.readIDParams(r)
+	if err != nil {
+		app.notFoundResponse(w, r)
+		return
+	}
+
+	titleID, err := app.readInt64Param(r, "title_id")
+	if err != nil {
+		app.notFoundResponse(w, r)
+		return
+	}
+
+	err = app.models.Titles.Delete(id, titleID, app.contextGetUser(r).ID)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrRecordNotFound):
+			app.notFoundResponse(w, r)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	err = app.writeJSON(w, http.StatusOK, envelope{"message": "successfully deleted"}, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
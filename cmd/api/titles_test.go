@@ -0,0 +1,43 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/petrostrak/gomdb/internal/data"
+	"golang.org/x/text/language"
+)
+
+func Test_localizedTitle(t *testing.T) {
+	titles := []*data.AlternateTitle{
+		{Title: "Le Fabuleux Destin d'Amélie Poulain", Language: "fr", Type: data.TitleTypeOriginal},
+		{Title: "Die fabelhafte Welt der Amélie", Language: "de", Type: data.TitleTypeTranslated},
+		{Title: "O Fabuloso Destino de Amélie Poulain", Language: "pt", Region: "BR", Type: data.TitleTypeTranslated},
+		{Title: "Amélie from Montmartre", Language: "en", Type: data.TitleTypeWorking},
+	}
+
+	tests := []struct {
+		acceptLanguage string
+		expected       string
+	}{
+		{"", "Amélie"},
+		{"de-AT, en;q=0.5", "Die fabelhafte Welt der Amélie"},
+		{"fr", "Le Fabuleux Destin d'Amélie Poulain"},
+		{"pt-BR", "O Fabuloso Destino de Amélie Poulain"},
+		{"en", "Amélie"},
+		{"ja", "Amélie"},
+	}
+
+	for _, tt := range tests {
+		preferred, _, _ := language.ParseAcceptLanguage(tt.acceptLanguage)
+
+		title, original := localizedTitle("Amélie", titles, preferred)
+
+		if title != tt.expected {
+			t.Errorf("%q: expected title %q but got %q", tt.acceptLanguage, tt.expected, title)
+		}
+
+		if original != "Le Fabuleux Destin d'Amélie Poulain" {
+			t.Errorf("%q: expected original title %q but got %q", tt.acceptLanguage, "Le Fabuleux Destin d'Amélie Poulain", original)
+		}
+	}
+}
@@ -0,0 +1,620 @@
+package data
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/lib/pq"
+	"github.com/petrostrak/gomdb/internal/validator"
+)
+
+var (
+	ErrDuplicateCollectionItem = errors.New("duplicate collection item")
+	ErrUnknownCollection       = errors.New("unknown collection")
+	ErrCollectionCycle         = errors.New("collection cycle")
+)
+
+// Collection is a named, ordered group of movies, such as a franchise. A collection
+// can be nested in a parent collection, among whose sub-collections it is ordered by
+// Position.
+type Collection struct {
+	ID          uuid.UUID     `json:"id"`
+	CreatedAt   time.Time     `json:"created_at"`
+	ParentID    uuid.NullUUID `json:"parent_id"`
+	Name        string        `json:"name"`
+	Description string        `json:"description,omitempty"`
+	Position    int32         `json:"position"`
+	MovieCount  int32         `json:"movie_count"`
+	Version     int32         `json:"version"`
+}
+
+// CollectionItem is a movie held in a collection, together with its position in that
+// collection.
+type CollectionItem struct {
+	Position int32     `json:"position"`
+	AddedAt  time.Time `json:"added_at"`
+	Movie    *Movie    `json:"movie"`
+}
+
+// MovieCollection is a collection as it appears on the movies it holds.
+type MovieCollection struct {
+	ID       uuid.UUID `json:"id"`
+	Name     string    `json:"name"`
+	Position int32     `json:"position"`
+}
+
+func ValidateCollection(v *validator.Validator, collection *Collection) {
+	v.Check(collection.Name != "", "name", "must be provided")
+	v.Check(len(collection.Name) <= 200, "name", "must not be more than 200 bytes long")
+
+	v.Check(len(collection.Description) <= 2_000, "description", "must not be more than 2000 bytes long")
+
+	v.Check(collection.Position >= 0, "position", "must not be negative")
+
+	if collection.ParentID.Valid {
+		v.Check(collection.ParentID.UUID != collection.ID, "parent_id", "must not be the collection itself")
+	}
+}
+
+type CollectionModel struct {
+	DB *sql.DB
+}
+
+func (m CollectionModel) Insert(collection *Collection) error {
+	query := `
+		INSERT INTO collections (parent_id, name, description, position)
+		VALUES ($1, $2, $3, $4)
+		RETURNING id, created_at, version`
+
+	args := []any{collection.ParentID, collection.Name, collection.Description, collection.Position}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	err := m.DB.QueryRowContext(ctx, query, args...).Scan(
+		&collection.ID,
+		&collection.CreatedAt,
+		&collection.Version,
+	)
+	if err != nil {
+		switch {
+		case err.Error() == `pq: insert or update on table "collections" violates foreign key constraint "collections_parent_id_fkey"`:
+			return ErrUnknownCollection
+		default:
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (m CollectionModel) Get(id uuid.UUID) (*Collection, error) {
+	if id == uuid.Nil {
+		return nil, ErrRecordNotFound
+	}
+
+	query := `
+		SELECT id, created_at, parent_id, name, description, position,
+			(SELECT count(*) FROM collection_items INNER JOIN movies ON movies.id = collection_items.movie_id
+			WHERE collection_items.collection_id = collections.id AND movies.deleted_at IS NULL), version
+		FROM collections
+		WHERE id = $1`
+
+	var collection Collection
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	err := m.DB.QueryRowContext(ctx, query, id).Scan(
+		&collection.ID,
+		&collection.CreatedAt,
+		&collection.ParentID,
+		&collection.Name,
+		&collection.Description,
+		&collection.Position,
+		&collection.MovieCount,
+		&collection.Version,
+	)
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return nil, ErrRecordNotFound
+		default:
+			return nil, err
+		}
+	}
+
+	return &collection, nil
+}
+
+// GetAll returns the sub-collections of a collection, or the top-level collections
+// when parentID is uuid.Nil.
+func (m CollectionModel) GetAll(parentID uuid.UUID, filters Filters) ([]*Collection, Metadata, error) {
+	query := fmt.Sprintf(`
+	SELECT count(*) OVER(), id, created_at, parent_id, name, description, position,
+		(SELECT count(*) FROM collection_items INNER JOIN movies ON movies.id = collection_items.movie_id
+			WHERE collection_items.collection_id = collections.id AND movies.deleted_at IS NULL), version
+	FROM collections
+	WHERE parent_id = $1 OR ($1 IS NULL AND parent_id IS NULL)
+	ORDER BY %s %s, id ASC
+	LIMIT $2 OFFSET $3`, filters.sortColumn(), filters.sortDirection())
+
+	args := []any{
+		uuid.NullUUID{UUID: parentID, Valid: parentID != uuid.Nil},
+		filters.limit(),
+		filters.offset(),
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	rows, err := m.DB.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, Metadata{}, err
+	}
+	defer rows.Close()
+
+	totalRecords := 0
+	collections := []*Collection{}
+
+	for rows.Next() {
+		var collection Collection
+		err := rows.Scan(
+			&totalRecords,
+			&collection.ID,
+			&collection.CreatedAt,
+			&collection.ParentID,
+			&collection.Name,
+			&collection.Description,
+			&collection.Position,
+			&collection.MovieCount,
+			&collection.Version,
+		)
+		if err != nil {
+			return nil, Metadata{}, err
+		}
+
+		collections = append(collections, &collection)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, Metadata{}, err
+	}
+
+	metadata := calculateMetadata(totalRecords, filters.Page, filters.PageSize)
+
+	return collections, metadata, nil
+}
+
+// Update saves the changes to a collection, provided that nobody else has changed it
+// since it was read. ErrCollectionCycle is returned if the new parent is the collection
+// itself or one of its sub-collections.
+func (m CollectionModel) Update(collection *Collection) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	tx, err := m.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	// Changes to the tree of collections are serialised, as two concurrent changes of
+	// parent could otherwise form a cycle which neither of them sees on its own.
+	_, err = tx.ExecContext(ctx, `LOCK TABLE collections IN SHARE ROW EXCLUSIVE MODE`)
+	if err != nil {
+		return err
+	}
+
+	if collection.ParentID.Valid {
+		query := `
+			WITH RECURSIVE ancestors AS (
+				SELECT id, parent_id FROM collections WHERE id = $1
+				UNION
+				SELECT collections.id, collections.parent_id
+				FROM collections
+				INNER JOIN ancestors ON collections.id = ancestors.parent_id
+			)
+			SELECT EXISTS (SELECT 1 FROM ancestors WHERE id = $2)`
+
+		var cycle bool
+
+		err = tx.QueryRowContext(ctx, query, collection.ParentID.UUID, collection.ID).Scan(&cycle)
+		if err != nil {
+			return err
+		}
+
+		if cycle {
+			return ErrCollectionCycle
+		}
+	}
+
+	query := `
+		UPDATE collections
+		SET parent_id = $1, name = $2, description = $3, position = $4, version = version + 1
+		WHERE id = $5 AND version = $6
+		RETURNING version`
+
+	args := []any{
+		collection.ParentID,
+		collection.Name,
+		collection.Description,
+		collection.Position,
+		collection.ID,
+		collection.Version,
+	}
+
+	err = tx.QueryRowContext(ctx, query, args...).Scan(&collection.Version)
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return ErrEditConflict
+		case err.Error() == `pq: insert or update on table "collections" violates foreign key constraint "collections_parent_id_fkey"`:
+			return ErrUnknownCollection
+		default:
+			return err
+		}
+	}
+
+	return tx.Commit()
+}
+
+// Delete removes a collection. Its sub-collections are moved up to its parent, and the
+// movies it held are kept.
+func (m CollectionModel) Delete(id uuid.UUID) error {
+	if id == uuid.Nil {
+		return ErrRecordNotFound
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	tx, err := m.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	_, err = tx.ExecContext(ctx, `LOCK TABLE collections IN SHARE ROW EXCLUSIVE MODE`)
+	if err != nil {
+		return err
+	}
+
+	query := `
+		UPDATE collections
+		SET parent_id = (SELECT parent_id FROM collections WHERE id = $1), version = version + 1
+		WHERE parent_id = $1`
+
+	_, err = tx.ExecContext(ctx, query, id)
+	if err != nil {
+		return err
+	}
+
+	result, err := tx.ExecContext(ctx, `DELETE FROM collections WHERE id = $1`, id)
+	if err != nil {
+		return err
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if rowsAffected == 0 {
+		return ErrRecordNotFound
+	}
+
+	return tx.Commit()
+}
+
+// GetMovies returns the movies held in a collection, by default in their order within
+// the collection.
+func (m CollectionModel) GetMovies(collectionID uuid.UUID, filters Filters) ([]*CollectionItem, Metadata, error) {
+	query := fmt.Sprintf(`
+	SELECT count(*) OVER(), collection_items.position, collection_items.added_at,
+		movies.id, movies.title, movies.year, movies.runtime, movies.genres, movies.status, movies.version
+	FROM collection_items
+	INNER JOIN movies ON movies.id = collection_items.movie_id
+	WHERE collection_items.collection_id = $1 AND movies.deleted_at IS NULL
+	ORDER BY %s %s, collection_items.movie_id ASC
+	LIMIT $2 OFFSET $3`, filters.sortColumn(), filters.sortDirection())
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	rows, err := m.DB.QueryContext(ctx, query, collectionID, filters.limit(), filters.offset())
+	if err != nil {
+		return nil, Metadata{}, err
+	}
+	defer rows.Close()
+
+	totalRecords := 0
+	items := []*CollectionItem{}
+
+	for rows.Next() {
+		var item CollectionItem
+		var movie Movie
+
+		err := rows.Scan(
+			&totalRecords,
+			&item.Position,
+			&item.AddedAt,
+			&movie.ID,
+			&movie.Title,
+			&movie.Year,
+			&movie.Runtime,
+			pq.Array(&movie.Genres),
+			&movie.Status,
+			&movie.Version,
+		)
+		if err != nil {
+			return nil, Metadata{}, err
+		}
+
+		item.Movie = &movie
+		items = append(items, &item)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, Metadata{}, err
+	}
+
+	metadata := calculateMetadata(totalRecords, filters.Page, filters.PageSize)
+
+	return items, metadata, nil
+}
+
+// GetAllForMovies returns the collections holding each of the movies, keyed by movie
+// id.
+func (m CollectionModel) GetAllForMovies(movieIDs []uuid.UUID) (map[uuid.UUID][]*MovieCollection, error) {
+	query := `
+		SELECT collection_items.movie_id, collections.id, collections.name, collection_items.position
+		FROM collection_items
+		INNER JOIN collections ON collections.id = collection_items.collection_id
+		WHERE collection_items.movie_id = ANY($1::uuid[])
+		ORDER BY collections.name, collections.id`
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	rows, err := m.DB.QueryContext(ctx, query, uuidArray(movieIDs))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	collections := make(map[uuid.UUID][]*MovieCollection)
+
+	for rows.Next() {
+		var (
+			movieID    uuid.UUID
+			collection MovieCollection
+		)
+
+		err := rows.Scan(&movieID, &collection.ID, &collection.Name, &collection.Position)
+		if err != nil {
+			return nil, err
+		}
+
+		collections[movieID] = append(collections[movieID], &collection)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return collections, nil
+}
+
+// AddMovie inserts a movie into a collection at the given position, shifting the movies
+// after it down by one. A position of zero, or one past the end of the collection,
+// appends it.
+func (m CollectionModel) AddMovie(collectionID, movieID uuid.UUID, position int32, userID uuid.UUID) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	tx, err := m.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	count, err := lockCollection(ctx, tx, collectionID)
+	if err != nil {
+		return err
+	}
+
+	if position < 1 || position > count+1 {
+		position = count + 1
+	}
+
+	query := `
+		UPDATE collection_items
+		SET position = position + 1
+		WHERE collection_id = $1 AND position >= $2`
+
+	_, err = tx.ExecContext(ctx, query, collectionID, position)
+	if err != nil {
+		return err
+	}
+
+	query = `
+		INSERT INTO collection_items (collection_id, movie_id, position)
+		VALUES ($1, $2, $3)`
+
+	_, err = tx.ExecContext(ctx, query, collectionID, movieID, position)
+	if err != nil {
+		switch {
+		case err.Error() == `pq: duplicate key value violates unique constraint "collection_items_pkey"`:
+			return ErrDuplicateCollectionItem
+		default:
+			return err
+		}
+	}
+
+	err = touchCollectionMovie(ctx, tx, movieID, userID)
+	if err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// MoveMovie moves a movie already held in a collection to a new position, shifting the
+// movies in between to close the gap.
+func (m CollectionModel) MoveMovie(collectionID, movieID uuid.UUID, position int32, userID uuid.UUID) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	tx, err := m.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	count, err := lockCollection(ctx, tx, collectionID)
+	if err != nil {
+		return err
+	}
+
+	query := `
+		SELECT position
+		FROM collection_items
+		WHERE collection_id = $1 AND movie_id = $2`
+
+	var current int32
+
+	err = tx.QueryRowContext(ctx, query, collectionID, movieID).Scan(&current)
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return ErrRecordNotFound
+		default:
+			return err
+		}
+	}
+
+	if position < 1 {
+		position = 1
+	}
+
+	if position > count {
+		position = count
+	}
+
+	query = `
+		UPDATE collection_items
+		SET position = CASE
+			WHEN movie_id = $2 THEN $4::integer
+			WHEN $3::integer < $4::integer THEN position - 1
+			ELSE position + 1
+		END
+		WHERE collection_id = $1
+		AND (movie_id = $2 OR position BETWEEN LEAST($3::integer, $4::integer) AND GREATEST($3::integer, $4::integer))`
+
+	_, err = tx.ExecContext(ctx, query, collectionID, movieID, current, position)
+	if err != nil {
+		return err
+	}
+
+	err = touchCollectionMovie(ctx, tx, movieID, userID)
+	if err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// RemoveMovie deletes a movie from a collection and closes the gap it leaves behind.
+func (m CollectionModel) RemoveMovie(collectionID, movieID, userID uuid.UUID) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	tx, err := m.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	_, err = lockCollection(ctx, tx, collectionID)
+	if err != nil {
+		return err
+	}
+
+	query := `
+		DELETE FROM collection_items
+		WHERE collection_id = $1 AND movie_id = $2
+		RETURNING position`
+
+	var position int32
+
+	err = tx.QueryRowContext(ctx, query, collectionID, movieID).Scan(&position)
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return ErrRecordNotFound
+		default:
+			return err
+		}
+	}
+
+	query = `
+		UPDATE collection_items
+		SET position = position - 1
+		WHERE collection_id = $1 AND position > $2`
+
+	_, err = tx.ExecContext(ctx, query, collectionID, position)
+	if err != nil {
+		return err
+	}
+
+	err = touchCollectionMovie(ctx, tx, movieID, userID)
+	if err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// lockCollection takes a row lock on a collection so that concurrent changes to the
+// order of its movies are serialised, and returns the number of movies it holds.
+func lockCollection(ctx context.Context, tx *sql.Tx, collectionID uuid.UUID) (int32, error) {
+	query := `
+		SELECT (SELECT count(*) FROM collection_items WHERE collection_items.collection_id = collections.id)
+		FROM collections
+		WHERE id = $1
+		FOR UPDATE`
+
+	var count int32
+
+	err := tx.QueryRowContext(ctx, query, collectionID).Scan(&count)
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return 0, ErrRecordNotFound
+		default:
+			return 0, err
+		}
+	}
+
+	return count, nil
+}
+
+// touchCollectionMovie bumps the version of a movie added to, moved within or removed
+// from a collection, and records a revision of it attributed to the given user. It is
+// called inside the transaction which made the change. The other movies of the
+// collection are left alone, as their entity tags follow the collections embedded in
+// them without a change of version.
+func touchCollectionMovie(ctx context.Context, tx *sql.Tx, movieID, userID uuid.UUID) error {
+	query := `
+		UPDATE movies
+		SET version = version + 1, updated_at = NOW()
+		WHERE id = $1
+		RETURNING id, title, year, runtime, genres, status, version`
+
+	_, err := reviseMovies(ctx, tx, query, []any{movieID}, userID)
+	return err
+}
@@ -0,0 +1,198 @@
+package data
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/petrostrak/gomdb/internal/validator"
+)
+
+const (
+	RoleDirector = "director"
+	RoleWriter   = "writer"
+	RoleActor    = "actor"
+)
+
+var (
+	ErrUnknownPerson = errors.New("unknown person")
+
+	// CreditRoles holds every role a person can be credited with on a movie.
+	CreditRoles = []string{RoleDirector, RoleWriter, RoleActor}
+)
+
+// Credit links a person to a movie in a specific role. The Character field only
+// applies to actors, and BillingOrder decides the order in which credits are listed.
+type Credit struct {
+	ID           int64     `json:"id"`
+	MovieID      uuid.UUID `json:"-"`
+	PersonID     uuid.UUID `json:"person_id"`
+	Name         string    `json:"name"`
+	Role         string    `json:"role"`
+	Character    string    `json:"character,omitempty"`
+	BillingOrder int32     `json:"billing_order"`
+}
+
+func ValidateCredit(v *validator.Validator, credit *Credit) {
+	v.Check(credit.PersonID != uuid.Nil, "person_id", "must be provided")
+
+	v.Check(credit.Role != "", "role", "must be provided")
+	v.Check(validator.In(credit.Role, CreditRoles...), "role", "must be one of director, writer or actor")
+
+	if credit.Role == RoleActor {
+		v.Check(len(credit.Character) <= 500, "character", "must not be more than 500 bytes long")
+	} else {
+		v.Check(credit.Character == "", "character", "must only be provided for actors")
+	}
+
+	v.Check(credit.BillingOrder >= 0, "billing_order", "must not be negative")
+}
+
+type CreditModel struct {
+	DB *sql.DB
+}
+
+// Insert adds a credit to a movie, and records a new version of the movie attributed to
+// the given user.
+func (m CreditModel) Insert(credit *Credit, userID uuid.UUID) error {
+	query := `
+		INSERT INTO movie_credits (movie_id, person_id, role, character, billing_order)
+		VALUES ($1, $2, $3, $4, $5)
+		RETURNING id, (SELECT name FROM people WHERE id = $2)`
+
+	args := []any{credit.MovieID, credit.PersonID, credit.Role, credit.Character, credit.BillingOrder}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	tx, err := m.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	err = touchMovie(ctx, tx, credit.MovieID, userID)
+	if err != nil {
+		return err
+	}
+
+	err = tx.QueryRowContext(ctx, query, args...).Scan(&credit.ID, &credit.Name)
+	if err != nil {
+		switch {
+		case err.Error() == `pq: insert or update on table "movie_credits" violates foreign key constraint "movie_credits_person_id_fkey"`:
+			return ErrUnknownPerson
+		default:
+			return err
+		}
+	}
+
+	return tx.Commit()
+}
+
+// GetAllForMovie returns the credits of a specific movie, directors and writers first
+// and then the cast in billing order.
+func (m CreditModel) GetAllForMovie(movieID uuid.UUID) ([]*Credit, error) {
+	credits, err := m.GetAllForMovies([]uuid.UUID{movieID})
+	if err != nil {
+		return nil, err
+	}
+
+	if credits[movieID] == nil {
+		return []*Credit{}, nil
+	}
+
+	return credits[movieID], nil
+}
+
+// GetAllForMovies returns the credits of several movies at once, keyed by movie id.
+// Movies without any credits are left out of the map.
+func (m CreditModel) GetAllForMovies(movieIDs []uuid.UUID) (map[uuid.UUID][]*Credit, error) {
+	query := `
+		SELECT movie_credits.id, movie_credits.movie_id, movie_credits.person_id, people.name,
+			movie_credits.role, movie_credits.character, movie_credits.billing_order
+		FROM movie_credits
+		INNER JOIN people ON people.id = movie_credits.person_id
+		WHERE movie_credits.movie_id = ANY($1::uuid[])
+		ORDER BY array_position(ARRAY['director', 'writer', 'actor'], movie_credits.role),
+			movie_credits.billing_order, movie_credits.id`
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	rows, err := m.DB.QueryContext(ctx, query, uuidArray(movieIDs))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	credits := make(map[uuid.UUID][]*Credit)
+
+	for rows.Next() {
+		var credit Credit
+
+		err := rows.Scan(
+			&credit.ID,
+			&credit.MovieID,
+			&credit.PersonID,
+			&credit.Name,
+			&credit.Role,
+			&credit.Character,
+			&credit.BillingOrder,
+		)
+		if err != nil {
+			return nil, err
+		}
+
+		credits[credit.MovieID] = append(credits[credit.MovieID], &credit)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return credits, nil
+}
+
+// Delete removes a credit, provided that it belongs to the given movie.
+// Delete removes a credit from a movie, and records a new version of the movie
+// attributed to the given user.
+func (m CreditModel) Delete(movieID uuid.UUID, id int64, userID uuid.UUID) error {
+	if id < 1 {
+		return ErrRecordNotFound
+	}
+
+	query := `
+		DELETE FROM movie_credits
+		WHERE id = $1 AND movie_id = $2`
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	tx, err := m.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	err = touchMovie(ctx, tx, movieID, userID)
+	if err != nil {
+		return err
+	}
+
+	result, err := tx.ExecContext(ctx, query, id, movieID)
+	if err != nil {
+		return err
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if rowsAffected == 0 {
+		return ErrRecordNotFound
+	}
+
+	return tx.Commit()
+}
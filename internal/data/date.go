@@ -0,0 +1,35 @@
+package data
+
+import (
+	"errors"
+	"strconv"
+	"time"
+)
+
+var ErrInvalidDateFormat = errors.New("invalid date format")
+
+// Date is a calendar date, without a time of day, which is encoded in JSON as a string
+// in the format "2006-01-02".
+type Date struct {
+	time.Time
+}
+
+func (d Date) MarshalJSON() ([]byte, error) {
+	return []byte(strconv.Quote(d.Format(time.DateOnly))), nil
+}
+
+func (d *Date) UnmarshalJSON(jsonValue []byte) error {
+	unquotedJSONValue, err := strconv.Unquote(string(jsonValue))
+	if err != nil {
+		return ErrInvalidDateFormat
+	}
+
+	t, err := time.Parse(time.DateOnly, unquotedJSONValue)
+	if err != nil {
+		return ErrInvalidDateFormat
+	}
+
+	d.Time = t
+
+	return nil
+}
@@ -0,0 +1,118 @@
+package data
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"regexp"
+
+	"github.com/google/uuid"
+	"github.com/lib/pq"
+	"github.com/petrostrak/gomdb/internal/validator"
+)
+
+var ErrDuplicateExternalID = errors.New("duplicate external id")
+
+// TrashedExternalIDError is returned instead of ErrDuplicateExternalID when the movie
+// already holding the external id is in the trash, as it can be restored rather than
+// created again.
+type TrashedExternalIDError struct {
+	MovieID uuid.UUID
+}
+
+func (e *TrashedExternalIDError) Error() string {
+	return fmt.Sprintf("external id held by trashed movie %s", e.MovieID)
+}
+
+// Sources of the external identifiers which a movie can have.
+const (
+	ExternalSourceIMDb = "imdb"
+	ExternalSourceTMDb = "tmdb"
+)
+
+// externalIDRX holds the format of the identifiers issued by each external source.
+var externalIDRX = map[string]*regexp.Regexp{
+	ExternalSourceIMDb: regexp.MustCompile(`^tt[0-9]{7,10}$`),
+	ExternalSourceTMDb: regexp.MustCompile(`^[1-9][0-9]{0,9}$`),
+}
+
+// ValidateExternalID checks that the source is supported and that the id is in the
+// format which the source issues.
+func ValidateExternalID(v *validator.Validator, source, id string) {
+	key := "external_ids." + source
+
+	rx, ok := externalIDRX[source]
+	if !ok {
+		v.AddError(key, "is not a supported source")
+		return
+	}
+
+	v.Check(validator.Matches(id, rx), key, fmt.Sprintf("must be a valid %s id", source))
+}
+
+// saveExternalIDs makes the external identifiers stored for a movie match the ones it
+// currently has. It is called inside the transaction which inserted or updated the
+// movie.
+func saveExternalIDs(ctx context.Context, tx *sql.Tx, movie *Movie) error {
+	sources := make([]string, 0, len(movie.ExternalIDs))
+	ids := make([]string, 0, len(movie.ExternalIDs))
+
+	for source, id := range movie.ExternalIDs {
+		sources = append(sources, source)
+		ids = append(ids, id)
+	}
+
+	query := `
+		DELETE FROM movie_external_ids
+		WHERE movie_id = $1 AND NOT source = ANY($2)`
+
+	_, err := tx.ExecContext(ctx, query, movie.ID, textArray(sources))
+	if err != nil {
+		return err
+	}
+
+	if len(sources) == 0 {
+		return nil
+	}
+
+	// The ids of movies in the trash are kept, so that they can be restored with them.
+	// A clash with one of those is looked for up front, as the failed insert would
+	// abort the transaction.
+	query = `
+		SELECT movie_external_ids.movie_id
+		FROM movie_external_ids
+		INNER JOIN movies ON movies.id = movie_external_ids.movie_id
+		INNER JOIN unnest($2::text[], $3::text[]) AS ids (source, external_id)
+			ON movie_external_ids.source = ids.source AND movie_external_ids.external_id = ids.external_id
+		WHERE movie_external_ids.movie_id <> $1 AND movies.deleted_at IS NOT NULL
+		LIMIT 1`
+
+	var trashedID uuid.UUID
+
+	err = tx.QueryRowContext(ctx, query, movie.ID, pq.Array(sources), pq.Array(ids)).Scan(&trashedID)
+	switch {
+	case err == nil:
+		return &TrashedExternalIDError{MovieID: trashedID}
+	case !errors.Is(err, sql.ErrNoRows):
+		return err
+	}
+
+	query = `
+		INSERT INTO movie_external_ids (movie_id, source, external_id)
+		SELECT $1, source, external_id
+		FROM unnest($2::text[], $3::text[]) AS ids (source, external_id)
+		ON CONFLICT (movie_id, source) DO UPDATE SET external_id = EXCLUDED.external_id`
+
+	_, err = tx.ExecContext(ctx, query, movie.ID, pq.Array(sources), pq.Array(ids))
+	if err != nil {
+		switch {
+		case err.Error() == `pq: duplicate key value violates unique constraint "movie_external_ids_source_external_id_key"`:
+			return ErrDuplicateExternalID
+		default:
+			return err
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,34 @@
+package data
+
+import (
+	"testing"
+
+	"github.com/petrostrak/gomdb/internal/validator"
+)
+
+func TestValidateExternalID(t *testing.T) {
+	tests := []struct {
+		source string
+		id     string
+		valid  bool
+	}{
+		{ExternalSourceIMDb, "tt0078748", true},
+		{ExternalSourceIMDb, "tt10872600", true},
+		{ExternalSourceIMDb, "0078748", false},
+		{ExternalSourceIMDb, "tt123", false},
+		{ExternalSourceTMDb, "348", true},
+		{ExternalSourceTMDb, "0348", false},
+		{ExternalSourceTMDb, "tt0078748", false},
+		{"letterboxd", "alien", false},
+	}
+
+	for _, tt := range tests {
+		v := validator.New()
+
+		ValidateExternalID(v, tt.source, tt.id)
+
+		if v.Valid() != tt.valid {
+			t.Errorf("%s %q: expected valid to be %t but got errors %v", tt.source, tt.id, tt.valid, v.Errors)
+		}
+	}
+}
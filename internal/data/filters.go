@@ -1,9 +1,12 @@
 package data
 
 import (
+	"encoding/base64"
+	"encoding/json"
 	"math"
 	"strings"
 
+	"github.com/google/uuid"
 	"github.com/petrostrak/gomdb/internal/validator"
 )
 
@@ -12,6 +15,51 @@ type Filters struct {
 	PageSize     int
 	Sort         string
 	SortSafelist []string
+	// Cursor is set when the results are paginated by keyset rather than by page
+	// number, in which case Page is ignored.
+	Cursor *Cursor
+}
+
+// Cursor marks a position in a keyset-paginated listing, given by the value of the
+// sort column and the id of the row on the edge of a page. It is handed to clients as
+// an opaque string.
+type Cursor struct {
+	Sort     string    `json:"s"`
+	Value    string    `json:"v"`
+	ID       uuid.UUID `json:"i"`
+	Backward bool      `json:"b,omitempty"`
+}
+
+// DecodeCursor parses a cursor previously returned in the pagination metadata. An empty
+// string decodes to a cursor for the first page.
+func DecodeCursor(s string) (*Cursor, error) {
+	var cursor Cursor
+
+	if s == "" {
+		return &cursor, nil
+	}
+
+	b, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil {
+		return nil, err
+	}
+
+	err = json.Unmarshal(b, &cursor)
+	if err != nil {
+		return nil, err
+	}
+
+	return &cursor, nil
+}
+
+func (c Cursor) encode() string {
+	b, _ := json.Marshal(c)
+	return base64.RawURLEncoding.EncodeToString(b)
+}
+
+// first reports whether the cursor points at the start of the listing.
+func (c Cursor) first() bool {
+	return c.Sort == ""
 }
 
 // Check that the client-provided Sort field matches one of the entries in our safelist
@@ -35,6 +83,23 @@ func (f Filters) sortDirection() string {
 	return "ASC"
 }
 
+// reverseDirection turns ASC into DESC and vice versa.
+func reverseDirection(direction string) string {
+	if direction == "ASC" {
+		return "DESC"
+	}
+	return "ASC"
+}
+
+// comparisonOperator returns the operator which selects the rows coming after a given
+// row when ordering in the given direction.
+func comparisonOperator(direction string) string {
+	if direction == "ASC" {
+		return ">"
+	}
+	return "<"
+}
+
 func (f Filters) limit() int {
 	return f.PageSize
 }
@@ -49,15 +114,21 @@ func ValidateFilters(v *validator.Validator, f Filters) {
 	v.Check(f.PageSize > 0, "page_size", "must be greater than zero")
 	v.Check(f.PageSize <= 100, "page_size", "must be a maximum of 100")
 	v.Check(validator.In(f.Sort, f.SortSafelist...), "sort", "invalid sort value")
+
+	if f.Cursor != nil && !f.Cursor.first() {
+		v.Check(f.Cursor.Sort == f.Sort, "cursor", "must be used with the sort it was created for")
+	}
 }
 
 // Metadata holds the pagination metadata.
 type Metadata struct {
-	CurrentPage  int `json:"current_page,omitempty"`
-	PageSize     int `json:"page_size,omitempty"`
-	FirstPage    int `json:"first_page,omitempty"`
-	LastPage     int `json:"last_page,omitempty"`
-	TotalRecords int `json:"total_records,omitempty"`
+	CurrentPage  int    `json:"current_page,omitempty"`
+	PageSize     int    `json:"page_size,omitempty"`
+	FirstPage    int    `json:"first_page,omitempty"`
+	LastPage     int    `json:"last_page,omitempty"`
+	TotalRecords int    `json:"total_records,omitempty"`
+	NextCursor   string `json:"next_cursor,omitempty"`
+	PrevCursor   string `json:"prev_cursor,omitempty"`
 }
 
 // The calculateMetadata() function calculates the appropriate pagination metadata
@@ -0,0 +1,30 @@
+package data
+
+import (
+	"testing"
+
+	"github.com/google/uuid"
+)
+
+func TestDecodeCursor(t *testing.T) {
+	want := Cursor{Sort: "-title", Value: "Alien", ID: uuid.New(), Backward: true}
+
+	got, err := DecodeCursor(want.encode())
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	if *got != want {
+		t.Errorf("expected %+v but got %+v", want, *got)
+	}
+
+	got, err = DecodeCursor("")
+	if err != nil || !got.first() {
+		t.Errorf("expected an empty cursor to point at the first page, got %+v, %v", got, err)
+	}
+
+	_, err = DecodeCursor("not a cursor")
+	if err == nil {
+		t.Error("expected an error for an invalid cursor")
+	}
+}
@@ -0,0 +1,503 @@
+package data
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"regexp"
+	"slices"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/lib/pq"
+	"github.com/petrostrak/gomdb/internal/validator"
+)
+
+const (
+	// genreRewriteBatchSize is the number of movies rewritten at a time when a genre is
+	// renamed or merged.
+	genreRewriteBatchSize = 1000
+
+	// genreRewriteTimeout bounds renaming or merging a genre, which rewrites every movie
+	// catalogued under it and so takes longer than other changes.
+	genreRewriteTimeout = 2 * time.Minute
+)
+
+var (
+	ErrDuplicateGenre = errors.New("duplicate genre")
+	ErrUnknownGenre   = errors.New("unknown genre")
+	ErrGenreCycle     = errors.New("genre cycle")
+
+	// SlugRX matches lowercase slugs made of words joined by hyphens, such as "sci-fi".
+	SlugRX = regexp.MustCompile(`^[a-z0-9]+(-[a-z0-9]+)*$`)
+)
+
+// Genre is a genre which movies can be catalogued under, identified by its slug. Names
+// holds its display names keyed by language, and Name the one picked for the client.
+// A genre can be nested under a parent genre.
+type Genre struct {
+	Slug       string            `json:"slug"`
+	Parent     string            `json:"parent,omitempty"`
+	Name       string            `json:"name,omitempty"`
+	Names      map[string]string `json:"names"`
+	MovieCount int64             `json:"movie_count"`
+	Version    int32             `json:"version"`
+}
+
+func ValidateGenre(v *validator.Validator, genre *Genre) {
+	v.Check(genre.Slug != "", "slug", "must be provided")
+	v.Check(len(genre.Slug) <= 50, "slug", "must not be more than 50 bytes long")
+	v.Check(validator.Matches(genre.Slug, SlugRX), "slug", "must only contain lowercase letters, digits and single hyphens between them")
+
+	v.Check(genre.Parent != genre.Slug, "parent", "must not be the genre itself")
+
+	v.Check(len(genre.Names) >= 1, "names", "must contain at least 1 name")
+
+	for language, name := range genre.Names {
+		v.Check(validator.Matches(language, LanguageRX), "names", "must be keyed by lowercase ISO 639 language codes")
+		v.Check(name != "", "names", "must not contain empty names")
+		v.Check(len(name) <= 100, "names", "must not contain names more than 100 bytes long")
+	}
+}
+
+type GenreModel struct {
+	DB *sql.DB
+}
+
+func (m GenreModel) Insert(genre *Genre) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	tx, err := m.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	query := `
+		INSERT INTO genres (slug, parent)
+		VALUES ($1, NULLIF($2, ''))
+		RETURNING version`
+
+	err = tx.QueryRowContext(ctx, query, genre.Slug, genre.Parent).Scan(&genre.Version)
+	if err != nil {
+		switch {
+		case err.Error() == `pq: duplicate key value violates unique constraint "genres_pkey"`:
+			return ErrDuplicateGenre
+		case err.Error() == `pq: insert or update on table "genres" violates foreign key constraint "genres_parent_fkey"`:
+			return ErrUnknownGenre
+		default:
+			return err
+		}
+	}
+
+	err = setGenreNames(ctx, tx, genre.Slug, genre.Names)
+	if err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+func (m GenreModel) Get(slug string) (*Genre, error) {
+	query := `
+		SELECT slug, COALESCE(parent, ''), version,
+			(SELECT count(*) FROM movies WHERE movies.genres @> ARRAY[genres.slug] AND movies.deleted_at IS NULL)
+		FROM genres
+		WHERE slug = $1`
+
+	var genre Genre
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	err := m.DB.QueryRowContext(ctx, query, slug).Scan(
+		&genre.Slug,
+		&genre.Parent,
+		&genre.Version,
+		&genre.MovieCount,
+	)
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return nil, ErrRecordNotFound
+		default:
+			return nil, err
+		}
+	}
+
+	names, err := m.getNames(ctx, []string{genre.Slug})
+	if err != nil {
+		return nil, err
+	}
+
+	genre.Names = names[genre.Slug]
+
+	return &genre, nil
+}
+
+// GetAll returns every genre, together with the number of movies catalogued under it.
+func (m GenreModel) GetAll() ([]*Genre, error) {
+	query := `
+		SELECT slug, COALESCE(parent, ''), version,
+			(SELECT count(*) FROM movies WHERE movies.genres @> ARRAY[genres.slug] AND movies.deleted_at IS NULL)
+		FROM genres
+		ORDER BY slug`
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	rows, err := m.DB.QueryContext(ctx, query)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	genres := []*Genre{}
+	slugs := []string{}
+
+	for rows.Next() {
+		var genre Genre
+
+		err := rows.Scan(
+			&genre.Slug,
+			&genre.Parent,
+			&genre.Version,
+			&genre.MovieCount,
+		)
+		if err != nil {
+			return nil, err
+		}
+
+		genres = append(genres, &genre)
+		slugs = append(slugs, genre.Slug)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	names, err := m.getNames(ctx, slugs)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, genre := range genres {
+		genre.Names = names[genre.Slug]
+	}
+
+	return genres, nil
+}
+
+// Slugs returns the slugs of every genre, which are the only genres movies can have.
+func (m GenreModel) Slugs() ([]string, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	var slugs []string
+
+	err := m.DB.QueryRowContext(ctx, `SELECT ARRAY(SELECT slug FROM genres ORDER BY slug)`).Scan(pq.Array(&slugs))
+	if err != nil {
+		return nil, err
+	}
+
+	return slugs, nil
+}
+
+// Resolve maps the slugs of genres which have since been renamed or merged into another
+// one, such as those found in old revisions of movies, to the slugs of the genres now
+// standing for them, dropping any duplicates this creates. Slugs of current genres, and
+// unknown ones, are returned as they are.
+func (m GenreModel) Resolve(slugs []string) ([]string, error) {
+	query := `
+		SELECT COALESCE(genres.slug, genre_redirects.new_slug, input.slug)
+		FROM unnest($1::text[]) WITH ORDINALITY AS input (slug, position)
+		LEFT JOIN genres ON genres.slug = input.slug
+		LEFT JOIN genre_redirects ON genre_redirects.old_slug = input.slug
+		ORDER BY input.position`
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	rows, err := m.DB.QueryContext(ctx, query, pq.Array(slugs))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	resolved := make([]string, 0, len(slugs))
+
+	for rows.Next() {
+		var slug string
+
+		err := rows.Scan(&slug)
+		if err != nil {
+			return nil, err
+		}
+
+		if !slices.Contains(resolved, slug) {
+			resolved = append(resolved, slug)
+		}
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return resolved, nil
+}
+
+// Update changes the parent and names of the genre with the given slug, and renames it
+// to genre.Slug. Renaming it rewrites the genres of every movie catalogued under it,
+// recording a revision of each attributed to the given user, in the same transaction,
+// and keeps the old slug as a redirect to the new one.
+func (m GenreModel) Update(genre *Genre, slug string, userID uuid.UUID) error {
+	ctx, cancel := context.WithTimeout(context.Background(), genreRewriteTimeout)
+	defer cancel()
+
+	tx, err := m.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	// Changes to the tree of genres are serialised, as two concurrent changes of parent
+	// could otherwise form a cycle which neither of them sees on its own.
+	_, err = tx.ExecContext(ctx, `LOCK TABLE genres IN SHARE ROW EXCLUSIVE MODE`)
+	if err != nil {
+		return err
+	}
+
+	if genre.Parent != "" {
+		query := `
+			WITH RECURSIVE ancestors AS (
+				SELECT slug, parent FROM genres WHERE slug = $1
+				UNION
+				SELECT genres.slug, genres.parent
+				FROM genres
+				INNER JOIN ancestors ON genres.slug = ancestors.parent
+			)
+			SELECT EXISTS (SELECT 1 FROM ancestors WHERE slug = $2)`
+
+		var cycle bool
+
+		err = tx.QueryRowContext(ctx, query, genre.Parent, slug).Scan(&cycle)
+		if err != nil {
+			return err
+		}
+
+		if cycle {
+			return ErrGenreCycle
+		}
+	}
+
+	query := `
+		UPDATE genres
+		SET slug = $1, parent = NULLIF($2, ''), version = version + 1
+		WHERE slug = $3 AND version = $4
+		RETURNING version`
+
+	args := []any{genre.Slug, genre.Parent, slug, genre.Version}
+
+	err = tx.QueryRowContext(ctx, query, args...).Scan(&genre.Version)
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return ErrEditConflict
+		case err.Error() == `pq: duplicate key value violates unique constraint "genres_pkey"`:
+			return ErrDuplicateGenre
+		case err.Error() == `pq: insert or update on table "genres" violates foreign key constraint "genres_parent_fkey"`:
+			return ErrUnknownGenre
+		default:
+			return err
+		}
+	}
+
+	err = setGenreNames(ctx, tx, genre.Slug, genre.Names)
+	if err != nil {
+		return err
+	}
+
+	if genre.Slug != slug {
+		err = redirectGenre(ctx, tx, slug, genre.Slug)
+		if err != nil {
+			return err
+		}
+
+		err = rewriteMovieGenres(ctx, tx, `array_replace(genres, $1, $2)`, slug, genre.Slug, userID)
+		if err != nil {
+			return err
+		}
+	}
+
+	return tx.Commit()
+}
+
+// Merge folds the genre with the given slug into another one. Movies catalogued under
+// it are moved to the surviving genre, keeping its position in their genres unless they
+// already had the surviving one, and a revision of each is recorded, attributed to the
+// given user. Its sub-genres are moved up to its parent, and its slug is kept as a
+// redirect to the surviving genre.
+func (m GenreModel) Merge(slug, intoSlug string, userID uuid.UUID) error {
+	ctx, cancel := context.WithTimeout(context.Background(), genreRewriteTimeout)
+	defer cancel()
+
+	tx, err := m.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	_, err = tx.ExecContext(ctx, `LOCK TABLE genres IN SHARE ROW EXCLUSIVE MODE`)
+	if err != nil {
+		return err
+	}
+
+	var count int
+
+	err = tx.QueryRowContext(ctx, `SELECT count(*) FROM genres WHERE slug IN ($1, $2)`, slug, intoSlug).Scan(&count)
+	if err != nil {
+		return err
+	}
+
+	if count != 2 {
+		return ErrRecordNotFound
+	}
+
+	rewrite := `CASE WHEN genres @> ARRAY[$2::text] THEN array_remove(genres, $1) ELSE array_replace(genres, $1, $2) END`
+
+	err = rewriteMovieGenres(ctx, tx, rewrite, slug, intoSlug, userID)
+	if err != nil {
+		return err
+	}
+
+	err = redirectGenre(ctx, tx, slug, intoSlug)
+	if err != nil {
+		return err
+	}
+
+	queries := []string{
+		`UPDATE genres SET parent = (SELECT parent FROM genres WHERE slug = $1) WHERE parent = $1`,
+		`DELETE FROM genres WHERE slug = $1`,
+	}
+
+	for _, stmt := range queries {
+		_, err = tx.ExecContext(ctx, stmt, slug)
+		if err != nil {
+			return err
+		}
+	}
+
+	return tx.Commit()
+}
+
+// getNames returns the display names of each of the genres, keyed by slug and then by
+// language.
+func (m GenreModel) getNames(ctx context.Context, slugs []string) (map[string]map[string]string, error) {
+	query := `
+		SELECT genre, language, name
+		FROM genre_names
+		WHERE genre = ANY($1)`
+
+	rows, err := m.DB.QueryContext(ctx, query, pq.Array(slugs))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	names := make(map[string]map[string]string)
+
+	for rows.Next() {
+		var slug, language, name string
+
+		err := rows.Scan(&slug, &language, &name)
+		if err != nil {
+			return nil, err
+		}
+
+		if names[slug] == nil {
+			names[slug] = make(map[string]string)
+		}
+
+		names[slug][language] = name
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return names, nil
+}
+
+// setGenreNames replaces the display names of a genre.
+func setGenreNames(ctx context.Context, tx *sql.Tx, slug string, names map[string]string) error {
+	_, err := tx.ExecContext(ctx, `DELETE FROM genre_names WHERE genre = $1`, slug)
+	if err != nil {
+		return err
+	}
+
+	query := `
+		INSERT INTO genre_names (genre, language, name)
+		VALUES ($1, $2, $3)`
+
+	for language, name := range names {
+		_, err = tx.ExecContext(ctx, query, slug, language, name)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// rewriteMovieGenres replaces the genre with the given slug by another one in the genres
+// of every movie catalogued under it, using the given expression of the genres column,
+// where $1 and $2 stand for the old and new slugs. A revision of each movie is recorded,
+// attributed to the given user. The movies are rewritten a batch at a time, locked in
+// the order of their ids so as not to deadlock with other changes to several movies.
+// Earlier revisions are left as they were, and have the old slug mapped on revert.
+func rewriteMovieGenres(ctx context.Context, tx *sql.Tx, rewrite, slug, newSlug string, userID uuid.UUID) error {
+	query := fmt.Sprintf(`
+		UPDATE movies
+		SET genres = %s, version = version + 1, updated_at = NOW()
+		WHERE id IN (
+			SELECT id
+			FROM movies
+			WHERE genres @> ARRAY[$1::text]
+			ORDER BY id
+			LIMIT %d
+			FOR UPDATE
+		)
+		RETURNING id, title, year, runtime, genres, status, version`, rewrite, genreRewriteBatchSize)
+
+	for {
+		count, err := reviseMovies(ctx, tx, query, []any{slug, newSlug}, userID)
+		if err != nil {
+			return err
+		}
+
+		if count < genreRewriteBatchSize {
+			return nil
+		}
+	}
+}
+
+// redirectGenre records that the genre with the given slug is now known by another one,
+// including for the slugs it had itself replaced earlier.
+func redirectGenre(ctx context.Context, tx *sql.Tx, slug, newSlug string) error {
+	queries := []string{
+		`UPDATE genre_redirects SET new_slug = $2 WHERE new_slug = $1`,
+		`INSERT INTO genre_redirects (old_slug, new_slug) VALUES ($1, $2)
+		ON CONFLICT (old_slug) DO UPDATE SET new_slug = EXCLUDED.new_slug`,
+	}
+
+	for _, query := range queries {
+		_, err := tx.ExecContext(ctx, query, slug, newSlug)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,146 @@
+package data
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// IdempotencyKey records a request made with an Idempotency-Key header, and the
+// response it got, so that retries of the same request can be answered with the same
+// response instead of being processed again. Keys are scoped to the user who sent
+// them, which must not be anonymous, and to the method and path of the request.
+type IdempotencyKey struct {
+	UserID      uuid.UUID
+	Route       string
+	Key         string
+	RequestHash []byte
+	// Status is zero while the original request is still being processed.
+	Status  int
+	Headers map[string]string
+	Body    []byte
+	Expiry  time.Time
+}
+
+type IdempotencyModel struct {
+	DB *sql.DB
+}
+
+// Begin claims the key for a new request. If the key was already claimed, and hasn't
+// expired, the existing record is returned instead and the request must not be
+// processed.
+func (m IdempotencyModel) Begin(key *IdempotencyKey) (*IdempotencyKey, error) {
+	query := `
+		INSERT INTO idempotency_keys (user_id, route, key, request_hash, expiry)
+		VALUES ($1, $2, $3, $4, $5)
+		ON CONFLICT (user_id, route, key) DO UPDATE
+		SET request_hash = EXCLUDED.request_hash, status = NULL, headers = NULL, body = NULL,
+			created_at = NOW(), expiry = EXCLUDED.expiry
+		WHERE idempotency_keys.expiry <= NOW()`
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	result, err := m.DB.ExecContext(ctx, query, key.UserID, key.Route, key.Key, key.RequestHash, key.Expiry)
+	if err != nil {
+		return nil, err
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return nil, err
+	}
+
+	if rowsAffected == 1 {
+		return nil, nil
+	}
+
+	query = `
+		SELECT request_hash, COALESCE(status, 0), COALESCE(headers, '{}'), COALESCE(body, ''), expiry
+		FROM idempotency_keys
+		WHERE user_id = $1 AND route = $2 AND key = $3`
+
+	existing := IdempotencyKey{UserID: key.UserID, Route: key.Route, Key: key.Key}
+	var headers []byte
+
+	err = m.DB.QueryRowContext(ctx, query, key.UserID, key.Route, key.Key).Scan(
+		&existing.RequestHash,
+		&existing.Status,
+		&headers,
+		&existing.Body,
+		&existing.Expiry,
+	)
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			// The key expired and was purged in the meantime.
+			return m.Begin(key)
+		default:
+			return nil, err
+		}
+	}
+
+	err = json.Unmarshal(headers, &existing.Headers)
+	if err != nil {
+		return nil, err
+	}
+
+	return &existing, nil
+}
+
+// Complete stores the response to the request which claimed the key.
+func (m IdempotencyModel) Complete(key *IdempotencyKey) error {
+	headers, err := json.Marshal(key.Headers)
+	if err != nil {
+		return err
+	}
+
+	query := `
+		UPDATE idempotency_keys
+		SET status = $1, headers = $2, body = $3
+		WHERE user_id = $4 AND route = $5 AND key = $6`
+
+	args := []any{key.Status, headers, key.Body, key.UserID, key.Route, key.Key}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	_, err = m.DB.ExecContext(ctx, query, args...)
+	return err
+}
+
+// Release gives up a claimed key without storing a response, so that the request can
+// be retried.
+func (m IdempotencyModel) Release(key *IdempotencyKey) error {
+	query := `
+		DELETE FROM idempotency_keys
+		WHERE user_id = $1 AND route = $2 AND key = $3`
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	_, err := m.DB.ExecContext(ctx, query, key.UserID, key.Route, key.Key)
+	return err
+}
+
+// DeleteExpired removes every key whose expiry has passed, and returns how many were
+// removed.
+func (m IdempotencyModel) DeleteExpired() (int64, error) {
+	query := `
+		DELETE FROM idempotency_keys
+		WHERE expiry <= NOW()`
+
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	result, err := m.DB.ExecContext(ctx, query)
+	if err != nil {
+		return 0, err
+	}
+
+	return result.RowsAffected()
+}
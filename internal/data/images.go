@@ -0,0 +1,225 @@
+package data
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+const (
+	ImageKindPoster   = "poster"
+	ImageKindBackdrop = "backdrop"
+)
+
+// ImageKinds holds every kind of image a movie can have, at most one of each.
+var ImageKinds = []string{ImageKindPoster, ImageKindBackdrop}
+
+// MovieImage is a poster or backdrop of a movie. The uploaded image is stored together
+// with resized variants of it, and Keys maps the name of every variant to the storage
+// key it is saved under. URLs is left for the API to fill in from Keys.
+type MovieImage struct {
+	MovieID   uuid.UUID         `json:"-"`
+	Kind      string            `json:"-"`
+	CreatedAt time.Time         `json:"created_at"`
+	Width     int32             `json:"width"`
+	Height    int32             `json:"height"`
+	Keys      map[string]string `json:"-"`
+	URLs      map[string]string `json:"urls"`
+}
+
+type MovieImageModel struct {
+	DB *sql.DB
+}
+
+// Save stores the image of a movie, replacing any image of the same kind it already
+// had, which is returned so that its files can be deleted. A new version of the movie
+// is recorded, attributed to the given user, as its representation has changed.
+func (m MovieImageModel) Save(image *MovieImage, userID uuid.UUID) (*MovieImage, error) {
+	keys, err := json.Marshal(image.Keys)
+	if err != nil {
+		return nil, err
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	tx, err := m.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	err = touchMovie(ctx, tx, image.MovieID, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	replaced, err := getMovieImage(ctx, tx, image.MovieID, image.Kind)
+	if err != nil && !errors.Is(err, ErrRecordNotFound) {
+		return nil, err
+	}
+
+	query := `
+		INSERT INTO movie_images (movie_id, kind, width, height, keys)
+		VALUES ($1, $2, $3, $4, $5)
+		ON CONFLICT (movie_id, kind) DO UPDATE
+		SET width = EXCLUDED.width, height = EXCLUDED.height, keys = EXCLUDED.keys, created_at = NOW()
+		RETURNING created_at`
+
+	args := []any{image.MovieID, image.Kind, image.Width, image.Height, keys}
+
+	err = tx.QueryRowContext(ctx, query, args...).Scan(&image.CreatedAt)
+	if err != nil {
+		return nil, err
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		return nil, err
+	}
+
+	return replaced, nil
+}
+
+// Delete removes the image of the given kind from a movie, and returns it so that its
+// files can be deleted. A new version of the movie is recorded, attributed to the given
+// user.
+func (m MovieImageModel) Delete(movieID uuid.UUID, kind string, userID uuid.UUID) (*MovieImage, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	tx, err := m.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	err = touchMovie(ctx, tx, movieID, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	image, err := getMovieImage(ctx, tx, movieID, kind)
+	if err != nil {
+		return nil, err
+	}
+
+	query := `
+		DELETE FROM movie_images
+		WHERE movie_id = $1 AND kind = $2`
+
+	_, err = tx.ExecContext(ctx, query, movieID, kind)
+	if err != nil {
+		return nil, err
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		return nil, err
+	}
+
+	return image, nil
+}
+
+// GetAllForMovies returns the images of every given movie, keyed by movie and kind.
+func (m MovieImageModel) GetAllForMovies(movieIDs []uuid.UUID) (map[uuid.UUID]map[string]*MovieImage, error) {
+	query := `
+		SELECT movie_id, kind, created_at, width, height, keys
+		FROM movie_images
+		WHERE movie_id = ANY($1::uuid[])`
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	rows, err := m.DB.QueryContext(ctx, query, uuidArray(movieIDs))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	images := make(map[uuid.UUID]map[string]*MovieImage)
+
+	for rows.Next() {
+		image, err := scanMovieImage(rows)
+		if err != nil {
+			return nil, err
+		}
+
+		if images[image.MovieID] == nil {
+			images[image.MovieID] = make(map[string]*MovieImage)
+		}
+
+		images[image.MovieID][image.Kind] = image
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return images, nil
+}
+
+// touchMovie locks a movie, bumps its version and records it as a new revision
+// attributed to the given user, for changes to the resources which are part of its
+// representation but aren't fields of the movie itself. It returns ErrRecordNotFound if
+// the movie doesn't exist or is in the trash.
+func touchMovie(ctx context.Context, tx *sql.Tx, movieID, userID uuid.UUID) error {
+	query := `
+		UPDATE movies
+		SET version = version + 1, updated_at = NOW()
+		WHERE id = $1 AND deleted_at IS NULL
+		RETURNING id, title, year, runtime, genres, status, version`
+
+	rowsAffected, err := reviseMovies(ctx, tx, query, []any{movieID}, userID)
+	if err != nil {
+		return err
+	}
+
+	if rowsAffected == 0 {
+		return ErrRecordNotFound
+	}
+
+	return nil
+}
+
+func getMovieImage(ctx context.Context, tx *sql.Tx, movieID uuid.UUID, kind string) (*MovieImage, error) {
+	query := `
+		SELECT movie_id, kind, created_at, width, height, keys
+		FROM movie_images
+		WHERE movie_id = $1 AND kind = $2`
+
+	image, err := scanMovieImage(tx.QueryRowContext(ctx, query, movieID, kind))
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return nil, ErrRecordNotFound
+		default:
+			return nil, err
+		}
+	}
+
+	return image, nil
+}
+
+func scanMovieImage(row interface{ Scan(...any) error }) (*MovieImage, error) {
+	var (
+		image MovieImage
+		keys  []byte
+	)
+
+	err := row.Scan(&image.MovieID, &image.Kind, &image.CreatedAt, &image.Width, &image.Height, &keys)
+	if err != nil {
+		return nil, err
+	}
+
+	err = json.Unmarshal(keys, &image.Keys)
+	if err != nil {
+		return nil, err
+	}
+
+	return &image, nil
+}
@@ -0,0 +1,490 @@
+package data
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/lib/pq"
+	"github.com/petrostrak/gomdb/internal/validator"
+)
+
+const (
+	VisibilityPublic  = "public"
+	VisibilityPrivate = "private"
+)
+
+var ErrDuplicateListItem = errors.New("duplicate list item")
+
+// List is a named, ordered collection of movies kept by a user. Every user has exactly
+// one list flagged as their watchlist, which is created the first time it is used.
+type List struct {
+	ID          uuid.UUID `json:"id"`
+	CreatedAt   time.Time `json:"created_at"`
+	UserID      uuid.UUID `json:"user_id"`
+	Name        string    `json:"name"`
+	Description string    `json:"description,omitempty"`
+	Visibility  string    `json:"visibility"`
+	Watchlist   bool      `json:"watchlist"`
+	ItemCount   int32     `json:"item_count"`
+	Version     int32     `json:"version"`
+}
+
+// ListItem is a movie held in a list, together with its position in that list.
+type ListItem struct {
+	Position int32     `json:"position"`
+	AddedAt  time.Time `json:"added_at"`
+	Movie    *Movie    `json:"movie"`
+}
+
+func ValidateList(v *validator.Validator, list *List) {
+	v.Check(list.Name != "", "name", "must be provided")
+	v.Check(len(list.Name) <= 200, "name", "must not be more than 200 bytes long")
+
+	v.Check(len(list.Description) <= 2_000, "description", "must not be more than 2000 bytes long")
+
+	v.Check(validator.In(list.Visibility, VisibilityPublic, VisibilityPrivate), "visibility", "must be either public or private")
+}
+
+type ListModel struct {
+	DB *sql.DB
+}
+
+func (m ListModel) Insert(list *List) error {
+	query := `
+		INSERT INTO lists (user_id, name, description, visibility)
+		VALUES ($1, $2, $3, $4)
+		RETURNING id, created_at, version`
+
+	args := []any{list.UserID, list.Name, list.Description, list.Visibility}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	return m.DB.QueryRowContext(ctx, query, args...).Scan(
+		&list.ID,
+		&list.CreatedAt,
+		&list.Version,
+	)
+}
+
+func (m ListModel) Get(id uuid.UUID) (*List, error) {
+	if id == uuid.Nil {
+		return nil, ErrRecordNotFound
+	}
+
+	query := `
+		SELECT id, created_at, user_id, name, description, visibility, watchlist,
+			(SELECT count(*) FROM list_items INNER JOIN movies ON movies.id = list_items.movie_id
+			WHERE list_items.list_id = lists.id AND movies.deleted_at IS NULL), version
+		FROM lists
+		WHERE id = $1`
+
+	var list List
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	err := m.DB.QueryRowContext(ctx, query, id).Scan(
+		&list.ID,
+		&list.CreatedAt,
+		&list.UserID,
+		&list.Name,
+		&list.Description,
+		&list.Visibility,
+		&list.Watchlist,
+		&list.ItemCount,
+		&list.Version,
+	)
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return nil, ErrRecordNotFound
+		default:
+			return nil, err
+		}
+	}
+
+	return &list, nil
+}
+
+// GetWatchlist returns the watchlist of a specific user, creating it first if the
+// user doesn't have one yet.
+func (m ListModel) GetWatchlist(userID uuid.UUID) (*List, error) {
+	query := `
+		INSERT INTO lists (user_id, name, watchlist)
+		VALUES ($1, 'Watchlist', true)
+		ON CONFLICT (user_id) WHERE watchlist DO NOTHING`
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	_, err := m.DB.ExecContext(ctx, query, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	query = `
+		SELECT id
+		FROM lists
+		WHERE user_id = $1 AND watchlist`
+
+	var id uuid.UUID
+
+	err = m.DB.QueryRowContext(ctx, query, userID).Scan(&id)
+	if err != nil {
+		return nil, err
+	}
+
+	return m.Get(id)
+}
+
+func (m ListModel) Update(list *List) error {
+	query := `
+		UPDATE lists
+		SET name = $1, description = $2, visibility = $3, version = version + 1
+		WHERE id = $4 AND version = $5
+		RETURNING version`
+
+	args := []any{
+		list.Name,
+		list.Description,
+		list.Visibility,
+		list.ID,
+		list.Version,
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	err := m.DB.QueryRowContext(ctx, query, args...).Scan(&list.Version)
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return ErrEditConflict
+		default:
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (m ListModel) Delete(id uuid.UUID) error {
+	if id == uuid.Nil {
+		return ErrRecordNotFound
+	}
+
+	query := `
+		DELETE FROM lists
+		WHERE id = $1`
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	result, err := m.DB.ExecContext(ctx, query, id)
+	if err != nil {
+		return err
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if rowsAffected == 0 {
+		return ErrRecordNotFound
+	}
+
+	return nil
+}
+
+func (m ListModel) GetAllForUser(userID uuid.UUID, filters Filters) ([]*List, Metadata, error) {
+	query := fmt.Sprintf(`
+	SELECT count(*) OVER(), id, created_at, user_id, name, description, visibility, watchlist,
+		(SELECT count(*) FROM list_items INNER JOIN movies ON movies.id = list_items.movie_id
+			WHERE list_items.list_id = lists.id AND movies.deleted_at IS NULL), version
+	FROM lists
+	WHERE user_id = $1
+	ORDER BY watchlist DESC, %s %s, id ASC
+	LIMIT $2 OFFSET $3`, filters.sortColumn(), filters.sortDirection())
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	rows, err := m.DB.QueryContext(ctx, query, userID, filters.limit(), filters.offset())
+	if err != nil {
+		return nil, Metadata{}, err
+	}
+	defer rows.Close()
+
+	totalRecords := 0
+	lists := []*List{}
+
+	for rows.Next() {
+		var list List
+		err := rows.Scan(
+			&totalRecords,
+			&list.ID,
+			&list.CreatedAt,
+			&list.UserID,
+			&list.Name,
+			&list.Description,
+			&list.Visibility,
+			&list.Watchlist,
+			&list.ItemCount,
+			&list.Version,
+		)
+		if err != nil {
+			return nil, Metadata{}, err
+		}
+
+		lists = append(lists, &list)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, Metadata{}, err
+	}
+
+	metadata := calculateMetadata(totalRecords, filters.Page, filters.PageSize)
+
+	return lists, metadata, nil
+}
+
+// GetItems returns the movies held in a list, by default in the order chosen by the
+// owner of the list.
+func (m ListModel) GetItems(listID uuid.UUID, filters Filters) ([]*ListItem, Metadata, error) {
+	query := fmt.Sprintf(`
+	SELECT count(*) OVER(), list_items.position, list_items.added_at,
+		movies.id, movies.title, movies.year, movies.runtime, movies.genres, movies.status, movies.version
+	FROM list_items
+	INNER JOIN movies ON movies.id = list_items.movie_id
+	WHERE list_items.list_id = $1 AND movies.deleted_at IS NULL
+	ORDER BY %s %s, list_items.movie_id ASC
+	LIMIT $2 OFFSET $3`, filters.sortColumn(), filters.sortDirection())
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	rows, err := m.DB.QueryContext(ctx, query, listID, filters.limit(), filters.offset())
+	if err != nil {
+		return nil, Metadata{}, err
+	}
+	defer rows.Close()
+
+	totalRecords := 0
+	items := []*ListItem{}
+
+	for rows.Next() {
+		var item ListItem
+		var movie Movie
+
+		err := rows.Scan(
+			&totalRecords,
+			&item.Position,
+			&item.AddedAt,
+			&movie.ID,
+			&movie.Title,
+			&movie.Year,
+			&movie.Runtime,
+			pq.Array(&movie.Genres),
+			&movie.Status,
+			&movie.Version,
+		)
+		if err != nil {
+			return nil, Metadata{}, err
+		}
+
+		item.Movie = &movie
+		items = append(items, &item)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, Metadata{}, err
+	}
+
+	metadata := calculateMetadata(totalRecords, filters.Page, filters.PageSize)
+
+	return items, metadata, nil
+}
+
+// AddItem inserts a movie into a list at the given position, shifting the items after
+// it down by one. A position of zero, or one past the end of the list, appends it.
+func (m ListModel) AddItem(listID, movieID uuid.UUID, position int32) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	tx, err := m.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	count, err := lockList(ctx, tx, listID)
+	if err != nil {
+		return err
+	}
+
+	if position < 1 || position > count+1 {
+		position = count + 1
+	}
+
+	query := `
+		UPDATE list_items
+		SET position = position + 1
+		WHERE list_id = $1 AND position >= $2`
+
+	_, err = tx.ExecContext(ctx, query, listID, position)
+	if err != nil {
+		return err
+	}
+
+	query = `
+		INSERT INTO list_items (list_id, movie_id, position)
+		VALUES ($1, $2, $3)`
+
+	_, err = tx.ExecContext(ctx, query, listID, movieID, position)
+	if err != nil {
+		switch {
+		case err.Error() == `pq: duplicate key value violates unique constraint "list_items_pkey"`:
+			return ErrDuplicateListItem
+		default:
+			return err
+		}
+	}
+
+	return tx.Commit()
+}
+
+// MoveItem moves a movie already held in a list to a new position, shifting the items
+// in between to close the gap.
+func (m ListModel) MoveItem(listID, movieID uuid.UUID, position int32) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	tx, err := m.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	count, err := lockList(ctx, tx, listID)
+	if err != nil {
+		return err
+	}
+
+	query := `
+		SELECT position
+		FROM list_items
+		WHERE list_id = $1 AND movie_id = $2`
+
+	var current int32
+
+	err = tx.QueryRowContext(ctx, query, listID, movieID).Scan(&current)
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return ErrRecordNotFound
+		default:
+			return err
+		}
+	}
+
+	if position < 1 {
+		position = 1
+	}
+
+	if position > count {
+		position = count
+	}
+
+	query = `
+		UPDATE list_items
+		SET position = CASE
+			WHEN movie_id = $2 THEN $4::integer
+			WHEN $3::integer < $4::integer THEN position - 1
+			ELSE position + 1
+		END
+		WHERE list_id = $1
+		AND (movie_id = $2 OR position BETWEEN LEAST($3::integer, $4::integer) AND GREATEST($3::integer, $4::integer))`
+
+	_, err = tx.ExecContext(ctx, query, listID, movieID, current, position)
+	if err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// RemoveItem deletes a movie from a list and closes the gap it leaves behind.
+func (m ListModel) RemoveItem(listID, movieID uuid.UUID) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	tx, err := m.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	_, err = lockList(ctx, tx, listID)
+	if err != nil {
+		return err
+	}
+
+	query := `
+		DELETE FROM list_items
+		WHERE list_id = $1 AND movie_id = $2
+		RETURNING position`
+
+	var position int32
+
+	err = tx.QueryRowContext(ctx, query, listID, movieID).Scan(&position)
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return ErrRecordNotFound
+		default:
+			return err
+		}
+	}
+
+	query = `
+		UPDATE list_items
+		SET position = position - 1
+		WHERE list_id = $1 AND position > $2`
+
+	_, err = tx.ExecContext(ctx, query, listID, position)
+	if err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// lockList takes a row lock on a list so that concurrent changes to the order of its
+// items are serialised, and returns the number of items the list holds.
+func lockList(ctx context.Context, tx *sql.Tx, listID uuid.UUID) (int32, error) {
+	query := `
+		SELECT (SELECT count(*) FROM list_items WHERE list_items.list_id = lists.id)
+		FROM lists
+		WHERE id = $1
+		FOR UPDATE`
+
+	var count int32
+
+	err := tx.QueryRowContext(ctx, query, listID).Scan(&count)
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return 0, ErrRecordNotFound
+		default:
+			return 0, err
+		}
+	}
+
+	return count, nil
+}
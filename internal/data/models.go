@@ -11,17 +11,43 @@ var (
 )
 
 type Models struct {
-	Movies      MovieModel
-	Users       UserModel
-	Tokens      TokenModel
-	Permissions PermissionModel
+	Movies         MovieModel
+	Users          UserModel
+	Tokens         TokenModel
+	Permissions    PermissionModel
+	People         PersonModel
+	Credits        CreditModel
+	Reviews        ReviewModel
+	Lists          ListModel
+	Revisions      RevisionModel
+	Idempotency    IdempotencyModel
+	Images         MovieImageModel
+	Titles         AlternateTitleModel
+	Releases       ReleaseModel
+	Certifications CertificationModel
+	Collections    CollectionModel
+	Relations      RelationModel
+	Genres         GenreModel
 }
 
 func NewModels(db *sql.DB) Models {
 	return Models{
-		Movies:      MovieModel{DB: db},
-		Users:       UserModel{DB: db},
-		Tokens:      TokenModel{DB: db},
-		Permissions: PermissionModel{DB: db},
+		Movies:         MovieModel{DB: db},
+		Users:          UserModel{DB: db},
+		Tokens:         TokenModel{DB: db},
+		Permissions:    PermissionModel{DB: db},
+		People:         PersonModel{DB: db},
+		Credits:        CreditModel{DB: db},
+		Reviews:        ReviewModel{DB: db},
+		Lists:          ListModel{DB: db},
+		Revisions:      RevisionModel{DB: db},
+		Idempotency:    IdempotencyModel{DB: db},
+		Images:         MovieImageModel{DB: db},
+		Titles:         AlternateTitleModel{DB: db},
+		Releases:       ReleaseModel{DB: db},
+		Certifications: CertificationModel{DB: db},
+		Collections:    CollectionModel{DB: db},
+		Relations:      RelationModel{DB: db},
+		Genres:         GenreModel{DB: db},
 	}
 }
@@ -3,8 +3,12 @@ package data
 import (
 	"context"
 	"database/sql"
+	"encoding/json"
 	"errors"
 	"fmt"
+	"slices"
+	"strconv"
+	"strings"
 	"time"
 
 	"github.com/google/uuid"
@@ -13,52 +17,359 @@ import (
 )
 
 type Movie struct {
-	ID        uuid.UUID `json:"id"`
-	CreatedAt time.Time `json:"-"`
-	Title     string    `json:"title"`
-	Year      int32     `json:"year,omitempty"`
-	Runtime   Runtime   `json:"runtime,omitempty,string"`
-	Genres    []string  `json:"genres,omitempty"`
-	Version   int32     `json:"version"`
+	ID             uuid.UUID              `json:"id"`
+	CreatedAt      time.Time              `json:"-"`
+	UpdatedAt      time.Time              `json:"-"`
+	Title          string                 `json:"title"`
+	OriginalTitle  string                 `json:"original_title"`
+	Year           int32                  `json:"year,omitempty"`
+	Runtime        Runtime                `json:"runtime,omitempty,string"`
+	Genres         []string               `json:"genres,omitempty"`
+	Status         string                 `json:"status,omitempty"`
+	NextRelease    *Date                  `json:"next_release,omitempty"`
+	ExternalIDs    map[string]string      `json:"external_ids,omitempty"`
+	Images         map[string]*MovieImage `json:"images,omitempty"`
+	AverageRating  float64                `json:"average_rating,omitempty"`
+	RatingCount    int32                  `json:"rating_count,omitempty"`
+	Version        int32                  `json:"version"`
+	DeletedAt      *time.Time             `json:"deleted_at,omitempty"`
+	Headline       string                 `json:"headline,omitempty"`
+	Credits        []*Credit              `json:"credits,omitempty"`
+	Reviews        []*Review              `json:"reviews,omitempty"`
+	Releases       []*Release             `json:"releases,omitempty"`
+	Certifications []*Certification       `json:"certifications,omitempty"`
+	Collections    []*MovieCollection     `json:"collections,omitempty"`
 }
 
-func ValidateMovie(v *validator.Validator, movie *Movie) {
+// MarshalJSON always includes the original title of a movie. Unless Title has been
+// replaced with a localized title, the original title is the title the movie is
+// stored with.
+func (m Movie) MarshalJSON() ([]byte, error) {
+	type movie Movie
+
+	if m.OriginalTitle == "" {
+		m.OriginalTitle = m.Title
+	}
+
+	return json.Marshal(movie(m))
+}
+
+const (
+	MovieStatusAnnounced      = "announced"
+	MovieStatusInProduction   = "in_production"
+	MovieStatusPostProduction = "post_production"
+	MovieStatusReleased       = "released"
+	MovieStatusCancelled      = "cancelled"
+)
+
+var (
+	// ErrFutureReleaseYear is returned when a change would give a released movie a year
+	// in the future, which can happen through the year of its earliest release.
+	ErrFutureReleaseYear = errors.New("released movie with a future year")
+
+	// MovieStatuses holds every status a movie can have.
+	MovieStatuses = []string{MovieStatusAnnounced, MovieStatusInProduction, MovieStatusPostProduction, MovieStatusReleased, MovieStatusCancelled}
+
+	// UpcomingMovieStatuses holds the statuses of the movies which are yet to be
+	// released.
+	UpcomingMovieStatuses = []string{MovieStatusAnnounced, MovieStatusInProduction, MovieStatusPostProduction}
+)
+
+// Text search configurations which can be used to search movie titles. English stems
+// the words of the query, while simple matches them verbatim.
+const (
+	SearchLanguageEnglish = "english"
+	SearchLanguageSimple  = "simple"
+)
+
+var SearchLanguages = []string{SearchLanguageEnglish, SearchLanguageSimple}
+
+// movieSearchQuery is the text search query built from the title filter. It relies on
+// the argument numbering of MovieFilters.where.
+const movieSearchQuery = "plainto_tsquery($13::regconfig, $1)"
+
+// movieSortColumns maps the sort keys which don't match a column name onto the column
+// or expression the movies are actually ordered by.
+var movieSortColumns = map[string]string{
+	"rating": "average_rating",
+	// The rank is negated so that the most relevant movies come first when sorting in
+	// ascending order. Word similarity ranks the fuzzy matches, and breaks ties between
+	// full-text matches in favour of the closest titles, alternate titles included.
+	"relevance": "-(ts_rank_cd(search_vector, " + movieSearchQuery + ") + greatest(word_similarity($1, title), word_similarity($1, alternate_titles)))",
+}
+
+// movieHeadline selects the title with the words matching the title filter
+// highlighted, or an empty string when there is no title filter.
+const movieHeadline = "CASE WHEN $1 = '' THEN '' ELSE ts_headline($13::regconfig, title, " + movieSearchQuery + ") END"
+
+// ValidateMovie checks a movie according to its status. Only a released movie must
+// have a runtime, and only a released movie is kept from having a year in the future;
+// for any other movie the year is the one it is expected to be released in. Its genres
+// must be among the given slugs of the known genres.
+func ValidateMovie(v *validator.Validator, movie *Movie, genres []string) {
 	v.Check(movie.Title != "", "title", "must be provided")
 	v.Check(len(movie.Title) <= 500, "title", "must not be more than 500 bytes long")
 
+	v.Check(movie.Status != "", "status", "must be provided")
+	v.Check(validator.In(movie.Status, MovieStatuses...), "status", "must be one of announced, in_production, post_production, released or cancelled")
+
 	v.Check(movie.Year != 0, "year", "must be provided")
 	v.Check(movie.Year >= 1888, "year", "must be greater than 1888")
-	v.Check(movie.Year <= int32(time.Now().Year()), "year", "must not be in the future")
 
-	v.Check(movie.Runtime != 0, "runtime", "must be provided")
-	v.Check(movie.Runtime > 0, "runtime", "must be a positive integer")
+	if movie.Status == MovieStatusReleased {
+		v.Check(movie.Year <= int32(time.Now().Year()), "year", "must not be in the future for a released movie")
+
+		v.Check(movie.Runtime != 0, "runtime", "must be provided")
+		v.Check(movie.Runtime > 0, "runtime", "must be a positive integer")
+	} else {
+		v.Check(movie.Runtime >= 0, "runtime", "must not be negative")
+	}
 
 	v.Check(movie.Genres != nil, "genres", "must be provided")
 	v.Check(len(movie.Genres) >= 1, "genres", "must contain at least 1 genre")
 	v.Check(len(movie.Genres) <= 5, "genres", "must not contain more than 5 genres")
 	v.Check(validator.Unique(movie.Genres), "genres", "must not contain duplicate values")
+
+	for _, genre := range movie.Genres {
+		v.Check(validator.In(genre, genres...), "genres", "must only contain known genres")
+	}
+
+	for source, id := range movie.ExternalIDs {
+		ValidateExternalID(v, source, id)
+	}
 }
 
-type MovieModel struct {
-	DB *sql.DB
+// MovieFilters holds the reductive filters that can be applied when listing movies.
+// Zero values leave the corresponding filter out.
+type MovieFilters struct {
+	Title         string
+	Genres        []string
+	GenresAny     []string
+	ExcludeGenres []string
+	PersonID      uuid.UUID
+	Role          string
+	Language      string
+	YearMin       int
+	YearMax       int
+	RuntimeMin    int
+	RuntimeMax    int
+	CreatedAfter  time.Time
+	CreatedBefore time.Time
+	// ReleasedIn, ReleasedFrom and ReleasedTo select the movies with a release in the
+	// country, between the dates inclusive, or both.
+	ReleasedIn   string
+	ReleasedFrom time.Time
+	ReleasedTo   time.Time
 }
 
-func (m MovieModel) Insert(movie *Movie) error {
-	query := `
-		INSERT INTO movies (title, year, runtime, genres) 
-		VALUES ($1, $2, $3, $4)
-		RETURNING id, created_at, version`
+func ValidateMovieFilters(v *validator.Validator, mf MovieFilters) {
+	v.Check(validator.In(mf.Language, SearchLanguages...), "lang", "must be either english or simple")
+
+	if mf.Role != "" {
+		v.Check(validator.In(mf.Role, CreditRoles...), "role", "must be one of director, writer or actor")
+		v.Check(mf.PersonID != uuid.Nil, "role", "must be used together with person")
+	}
+
+	for _, genre := range mf.ExcludeGenres {
+		v.Check(!validator.In(genre, mf.Genres...), "exclude_genres", "must not contain genres which are also required")
+		v.Check(!validator.In(genre, mf.GenresAny...), "exclude_genres", "must not contain genres which are also in genres_any")
+	}
 
-	args := []any{movie.Title, movie.Year, movie.Runtime, pq.Array(movie.Genres)}
+	if mf.YearMin != 0 {
+		v.Check(mf.YearMin >= 1888, "year_min", "must be greater than 1888")
+	}
+
+	if mf.YearMax != 0 {
+		v.Check(mf.YearMax >= 1888, "year_max", "must be greater than 1888")
+		v.Check(mf.YearMax >= mf.YearMin, "year_max", "must not be less than year_min")
+	}
+
+	if mf.RuntimeMin != 0 {
+		v.Check(mf.RuntimeMin > 0, "runtime_min", "must be a positive integer")
+	}
+
+	if mf.RuntimeMax != 0 {
+		v.Check(mf.RuntimeMax > 0, "runtime_max", "must be a positive integer")
+		v.Check(mf.RuntimeMax >= mf.RuntimeMin, "runtime_max", "must not be less than runtime_min")
+	}
+
+	if !mf.CreatedAfter.IsZero() && !mf.CreatedBefore.IsZero() {
+		v.Check(mf.CreatedBefore.After(mf.CreatedAfter), "created_before", "must be later than created_after")
+	}
+
+	if mf.ReleasedIn != "" {
+		v.Check(validator.Matches(mf.ReleasedIn, CountryRX), "released_in", "must be an uppercase ISO 3166 country code")
+	}
+
+	if !mf.ReleasedFrom.IsZero() && !mf.ReleasedTo.IsZero() {
+		v.Check(!mf.ReleasedTo.Before(mf.ReleasedFrom), "released_between", "must not end before it starts")
+	}
+}
+
+// ValidateMovieSort checks that the sort can be applied together with the filters.
+func ValidateMovieSort(v *validator.Validator, mf MovieFilters, filters Filters) {
+	if filters.Sort == "relevance" {
+		v.Check(mf.Title != "", "sort", "relevance can only be used together with title")
+		v.Check(filters.Cursor == nil, "cursor", "cannot be used when sorting by relevance")
+	}
+}
+
+type MovieModel struct {
+	DB *sql.DB
+}
 
+// Insert adds a new movie and records it as the first revision, attributed to the
+// given user, in the same transaction.
+func (m MovieModel) Insert(movie *Movie, userID uuid.UUID) error {
 	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
 	defer cancel()
 
-	return m.DB.QueryRowContext(ctx, query, args...).Scan(
+	tx, err := m.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	err = insertMovie(ctx, tx, movie, userID)
+	if err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// insertMovie adds a new movie and its first revision inside a transaction.
+func insertMovie(ctx context.Context, tx *sql.Tx, movie *Movie, userID uuid.UUID) error {
+	query := `
+		INSERT INTO movies (title, year, runtime, genres, status) 
+		VALUES ($1, $2, $3, $4, $5)
+		RETURNING id, created_at, updated_at, version`
+
+	args := []any{movie.Title, movie.Year, movie.Runtime, pq.Array(movie.Genres), movie.Status}
+
+	err := tx.QueryRowContext(ctx, query, args...).Scan(
 		&movie.ID,
 		&movie.CreatedAt,
+		&movie.UpdatedAt,
 		&movie.Version,
 	)
+	if err != nil {
+		return err
+	}
+
+	err = saveExternalIDs(ctx, tx, movie)
+	if err != nil {
+		return err
+	}
+
+	return insertRevision(ctx, tx, movie, userID)
+}
+
+// importBatchSize is the number of movies InsertAll saves with a single statement.
+const importBatchSize = 500
+
+// InsertAll adds a batch of movies, each with its first revision attributed to the
+// given user, inside a single transaction, so that either all of them are saved or none
+// are. The movies are expected to have been validated already. They are sent to the
+// database a few hundred at a time, each group saved together with its revisions by a
+// single statement, which keeps the transaction short even for large imports.
+func (m MovieModel) InsertAll(movies []*Movie, userID uuid.UUID) error {
+	query := `
+		WITH inserted AS (
+			INSERT INTO movies (id, title, year, runtime, genres, status)
+			SELECT id, title, year, runtime, genres, status
+			FROM jsonb_to_recordset($1::jsonb) AS input (id uuid, title text, year integer, runtime integer, genres text[], status text)
+			RETURNING id, created_at, updated_at, title, year, runtime, genres, status, version
+		), revised AS (
+			INSERT INTO movie_revisions (movie_id, version, user_id, title, year, runtime, genres, status)
+			SELECT id, version, $2::uuid, title, year, runtime, genres, status
+			FROM inserted
+		)
+		SELECT id, created_at, updated_at, version
+		FROM inserted`
+
+	type row struct {
+		ID      uuid.UUID `json:"id"`
+		Title   string    `json:"title"`
+		Year    int32     `json:"year"`
+		Runtime int32     `json:"runtime"`
+		Genres  []string  `json:"genres"`
+		Status  string    `json:"status"`
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	tx, err := m.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	for start := 0; start < len(movies); start += importBatchSize {
+		batch := movies[start:min(start+importBatchSize, len(movies))]
+
+		// The ids are generated up front, so that the inserted rows can be matched up
+		// with the movies they were made from.
+		byID := make(map[uuid.UUID]*Movie, len(batch))
+		rows := make([]row, len(batch))
+
+		for i, movie := range batch {
+			movie.ID = uuid.New()
+			byID[movie.ID] = movie
+			rows[i] = row{movie.ID, movie.Title, movie.Year, int32(movie.Runtime), movie.Genres, movie.Status}
+		}
+
+		js, err := json.Marshal(rows)
+		if err != nil {
+			return err
+		}
+
+		err = insertMovieBatch(ctx, tx, query, js, userID, byID)
+		if err != nil {
+			return err
+		}
+
+		for _, movie := range batch {
+			if len(movie.ExternalIDs) == 0 {
+				continue
+			}
+
+			err = saveExternalIDs(ctx, tx, movie)
+			if err != nil {
+				return err
+			}
+		}
+	}
+
+	return tx.Commit()
+}
+
+// insertMovieBatch runs the statement saving a group of movies for InsertAll, and fills
+// in the generated fields of each of them.
+func insertMovieBatch(ctx context.Context, tx *sql.Tx, query string, js []byte, userID uuid.UUID, byID map[uuid.UUID]*Movie) error {
+	rows, err := tx.QueryContext(ctx, query, js, uuid.NullUUID{UUID: userID, Valid: userID != uuid.Nil})
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var id uuid.UUID
+		var createdAt, updatedAt time.Time
+		var version int32
+
+		err := rows.Scan(&id, &createdAt, &updatedAt, &version)
+		if err != nil {
+			return err
+		}
+
+		if movie, ok := byID[id]; ok {
+			movie.CreatedAt, movie.UpdatedAt, movie.Version = createdAt, updatedAt, version
+		}
+	}
+
+	return rows.Err()
 }
 
 func (m MovieModel) Get(id uuid.UUID) (*Movie, error) {
@@ -67,11 +378,15 @@ func (m MovieModel) Get(id uuid.UUID) (*Movie, error) {
 	}
 
 	query := `
-		SELECT id, created_at, title, year, runtime, genres, version
+		SELECT id, created_at, updated_at, title, year, runtime, genres, status, average_rating, rating_count, version,
+			(SELECT jsonb_object_agg(source, external_id) FROM movie_external_ids WHERE movie_id = movies.id)
 		FROM movies
-		WHERE id = $1`
+		WHERE id = $1 AND deleted_at IS NULL`
 
-	var movie Movie
+	var (
+		movie       Movie
+		externalIDs []byte
+	)
 
 	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
 	defer cancel()
@@ -79,11 +394,16 @@ func (m MovieModel) Get(id uuid.UUID) (*Movie, error) {
 	err := m.DB.QueryRowContext(ctx, query, id).Scan(
 		&movie.ID,
 		&movie.CreatedAt,
+		&movie.UpdatedAt,
 		&movie.Title,
 		&movie.Year,
 		&movie.Runtime,
 		pq.Array(&movie.Genres),
+		&movie.Status,
+		&movie.AverageRating,
+		&movie.RatingCount,
 		&movie.Version,
+		&externalIDs,
 	)
 
 	if err != nil {
@@ -95,15 +415,78 @@ func (m MovieModel) Get(id uuid.UUID) (*Movie, error) {
 		}
 	}
 
+	if externalIDs != nil {
+		err = json.Unmarshal(externalIDs, &movie.ExternalIDs)
+		if err != nil {
+			return nil, err
+		}
+	}
+
 	return &movie, nil
 }
 
-func (m MovieModel) Update(movie *Movie) error {
+// GetByExternalID returns the movie which has the given identifier from an external
+// source.
+func (m MovieModel) GetByExternalID(source, externalID string) (*Movie, error) {
+	query := `
+		SELECT movie_id
+		FROM movie_external_ids
+		WHERE source = $1 AND external_id = $2`
+
+	var id uuid.UUID
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	err := m.DB.QueryRowContext(ctx, query, source, externalID).Scan(&id)
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return nil, ErrRecordNotFound
+		default:
+			return nil, err
+		}
+	}
+
+	return m.Get(id)
+}
+
+// Update saves the changes to a movie, provided that nobody else has changed it since
+// it was read, and records the new version as a revision attributed to the given user.
+func (m MovieModel) Update(movie *Movie, userID uuid.UUID) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	tx, err := m.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	err = updateMovie(ctx, tx, movie)
+	if err != nil {
+		return err
+	}
+
+	err = insertRevision(ctx, tx, movie, userID)
+	if err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// updateMovie performs the version-checked update of a movie inside a transaction. A
+// movie which has releases keeps the year of its earliest release, whatever the year
+// it is given.
+func updateMovie(ctx context.Context, tx *sql.Tx, movie *Movie) error {
 	query := `
 		UPDATE movies
-		SET title = $1, year = $2, runtime = $3, genres = $4, version = version + 1 
-		WHERE id = $5 AND version = $6
-		RETURNING version`
+		SET title = $1,
+			year = COALESCE((SELECT date_part('year', min(date))::integer FROM movie_releases WHERE movie_id = $5), $2),
+			runtime = $3, genres = $4, status = $7, version = version + 1, updated_at = NOW()
+		WHERE id = $5 AND version = $6 AND deleted_at IS NULL
+		RETURNING year, version, updated_at`
 
 	args := []any{
 		movie.Title,
@@ -112,37 +495,42 @@ func (m MovieModel) Update(movie *Movie) error {
 		pq.Array(movie.Genres),
 		movie.ID,
 		movie.Version,
+		movie.Status,
 	}
 
-	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
-	defer cancel()
-
-	err := m.DB.QueryRowContext(ctx, query, args...).Scan(&movie.Version)
+	err := tx.QueryRowContext(ctx, query, args...).Scan(&movie.Year, &movie.Version, &movie.UpdatedAt)
 	if err != nil {
 		switch {
 		case errors.Is(err, sql.ErrNoRows):
 			return ErrEditConflict
+		case err.Error() == `pq: new row for relation "movies" violates check constraint "movies_year_check"`:
+			return ErrFutureReleaseYear
 		default:
 			return err
 		}
 	}
 
-	return nil
+	return saveExternalIDs(ctx, tx, movie)
 }
 
-func (m MovieModel) Delete(id uuid.UUID) error {
+// Delete moves a movie to the trash. Trashed movies are hidden from every other
+// query until they are either restored or purged. Unless version is zero, the movie is
+// only deleted if it is still at that version, and ErrEditConflict is returned
+// otherwise.
+func (m MovieModel) Delete(id uuid.UUID, version int32) error {
 	if id == uuid.Nil {
 		return ErrRecordNotFound
 	}
 
 	query := `
-		DELETE FROM movies
-		WHERE id = $1`
+		UPDATE movies
+		SET deleted_at = NOW()
+		WHERE id = $1 AND (version = $2 OR $2 = 0) AND deleted_at IS NULL`
 
 	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
 	defer cancel()
 
-	result, err := m.DB.ExecContext(ctx, query, id)
+	result, err := m.DB.ExecContext(ctx, query, id, version)
 	if err != nil {
 		return err
 	}
@@ -153,25 +541,125 @@ func (m MovieModel) Delete(id uuid.UUID) error {
 	}
 
 	if rowsAffected == 0 {
+		if version != 0 {
+			return ErrEditConflict
+		}
 		return ErrRecordNotFound
 	}
 
 	return nil
 }
 
-func (m MovieModel) GetAll(title string, genres []string, filters Filters) ([]*Movie, Metadata, error) {
+// where returns the WHERE clause selecting the movies which match the filters, together
+// with its arguments. Any further arguments must be numbered from len(args)+1.
+func (mf MovieFilters) where() (string, []any) {
+	language := mf.Language
+	if language == "" {
+		language = SearchLanguageSimple
+	}
+
+	// When no title matches the search at all, it has most likely been misspelled, so
+	// the titles which are similar to it are matched instead. Both kinds of match cover
+	// the alternate titles of the movies too.
+	clause := `
+	WHERE (search_vector @@ ` + movieSearchQuery + `
+		OR (($1 <% title OR $1 <% alternate_titles) AND NOT EXISTS (
+			SELECT 1 FROM movies AS matches
+			WHERE matches.search_vector @@ ` + movieSearchQuery + `
+			AND matches.deleted_at IS NULL
+		))
+		OR $1 = '')
+	AND (genres @> $2 OR $2 = '{}')
+	AND (EXISTS (
+		SELECT 1 FROM movie_credits
+		WHERE movie_credits.movie_id = movies.id
+		AND movie_credits.person_id = $3
+		AND (movie_credits.role = $4 OR $4 = '')
+	) OR $3 = '00000000-0000-0000-0000-000000000000')
+	AND (genres && $5 OR $5 = '{}')
+	AND NOT (genres && $6)
+	AND (year >= $7 OR $7 = 0)
+	AND (year <= $8 OR $8 = 0)
+	AND (runtime >= $9 OR $9 = 0)
+	AND (runtime <= $10 OR $10 = 0)
+	AND (created_at >= $11 OR $11 IS NULL)
+	AND (created_at < $12 OR $12 IS NULL)
+	AND (EXISTS (
+		SELECT 1 FROM movie_releases
+		WHERE movie_releases.movie_id = movies.id
+		AND (movie_releases.country = $14 OR $14 = '')
+		AND (movie_releases.date >= $15 OR $15 IS NULL)
+		AND (movie_releases.date <= $16 OR $16 IS NULL)
+	) OR ($14 = '' AND $15 IS NULL AND $16 IS NULL))
+	AND deleted_at IS NULL`
+
+	args := []any{
+		mf.Title,
+		textArray(mf.Genres),
+		mf.PersonID,
+		mf.Role,
+		textArray(mf.GenresAny),
+		textArray(mf.ExcludeGenres),
+		mf.YearMin,
+		mf.YearMax,
+		mf.RuntimeMin,
+		mf.RuntimeMax,
+		sql.NullTime{Time: mf.CreatedAfter, Valid: !mf.CreatedAfter.IsZero()},
+		sql.NullTime{Time: mf.CreatedBefore, Valid: !mf.CreatedBefore.IsZero()},
+		language,
+		mf.ReleasedIn,
+		sql.NullTime{Time: mf.ReleasedFrom, Valid: !mf.ReleasedFrom.IsZero()},
+		sql.NullTime{Time: mf.ReleasedTo, Valid: !mf.ReleasedTo.IsZero()},
+	}
+
+	return clause, args
+}
+
+// textArray wraps a slice of strings for use as a query argument. Unlike pq.Array it
+// sends a nil slice as an empty array rather than as NULL.
+func textArray(s []string) any {
+	if s == nil {
+		s = []string{}
+	}
+
+	return pq.Array(s)
+}
+
+// uuidArray wraps a slice of UUIDs for use as a query argument, which must be cast to
+// uuid[] in the query.
+func uuidArray(ids []uuid.UUID) any {
+	s := make([]string, len(ids))
+	for i, id := range ids {
+		s[i] = id.String()
+	}
+
+	return pq.Array(s)
+}
+
+// movieOrderBy returns the ORDER BY clause for the requested sort, using the movie id
+// as a tiebreaker so that the order is always stable.
+func movieOrderBy(filters Filters) string {
+	return fmt.Sprintf("ORDER BY %s %s, id ASC", movieSortColumn(filters), filters.sortDirection())
+}
+
+func (m MovieModel) GetAll(mf MovieFilters, filters Filters) ([]*Movie, Metadata, error) {
+	if filters.Cursor != nil {
+		return m.getAllByCursor(mf, filters)
+	}
+
+	where, args := mf.where()
+
 	query := fmt.Sprintf(`
-	SELECT count(*) OVER(), id, created_at, title, year, runtime, genres, version
+	SELECT count(*) OVER(), id, created_at, title, year, runtime, genres, status, average_rating, rating_count, version, %s
 	FROM movies
-	WHERE (to_tsvector('simple', title) @@ plainto_tsquery('simple', $1) OR $1 = '') 
-	AND (genres @> $2 OR $2 = '{}')
-	ORDER BY %s %s, id ASC
-	LIMIT $3 OFFSET $4`, filters.sortColumn(), filters.sortDirection())
+	%s
+	%s
+	LIMIT $%d OFFSET $%d`, movieHeadline, where, movieOrderBy(filters), len(args)+1, len(args)+2)
 
 	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
 	defer cancel()
 
-	args := []any{title, pq.Array(genres), filters.limit(), filters.offset()}
+	args = append(args, filters.limit(), filters.offset())
 	rows, err := m.DB.QueryContext(ctx, query, args...)
 	if err != nil {
 		return nil, Metadata{}, err
@@ -191,7 +679,11 @@ func (m MovieModel) GetAll(title string, genres []string, filters Filters) ([]*M
 			&movie.Year,
 			&movie.Runtime,
 			pq.Array(&movie.Genres),
+			&movie.Status,
+			&movie.AverageRating,
+			&movie.RatingCount,
 			&movie.Version,
+			&movie.Headline,
 		)
 		if err != nil {
 			return nil, Metadata{}, err
@@ -208,3 +700,918 @@ func (m MovieModel) GetAll(title string, genres []string, filters Filters) ([]*M
 
 	return movies, metadata, nil
 }
+
+// getAllByCursor returns the page of movies which follows (or, for a backward cursor,
+// precedes) the position marked by filters.Cursor. Rather than skipping over the rows
+// before it, the query seeks straight to the position, so every page is as fast as the
+// first. The total number of records isn't counted in this mode.
+func (m MovieModel) getAllByCursor(mf MovieFilters, filters Filters) ([]*Movie, Metadata, error) {
+	where, args := mf.where()
+
+	cursor := filters.Cursor
+	column := movieSortColumn(filters)
+
+	direction, idDirection := filters.sortDirection(), "ASC"
+	if cursor.Backward {
+		direction, idDirection = reverseDirection(direction), reverseDirection(idDirection)
+	}
+
+	if !cursor.first() {
+		op, idOp := comparisonOperator(direction), comparisonOperator(idDirection)
+
+		if column == "id" {
+			where += fmt.Sprintf(" AND id %s $%d", op, len(args)+1)
+			args = append(args, cursor.ID)
+		} else {
+			where += fmt.Sprintf(" AND (%[1]s %[2]s $%[4]d OR (%[1]s = $%[4]d AND id %[3]s $%[5]d))",
+				column, op, idOp, len(args)+1, len(args)+2)
+			args = append(args, cursor.Value, cursor.ID)
+		}
+	}
+
+	query := fmt.Sprintf(`
+	SELECT id, created_at, title, year, runtime, genres, status, average_rating, rating_count, version, %s
+	FROM movies
+	%s
+	ORDER BY %s %s, id %s
+	LIMIT $%d`, movieHeadline, where, column, direction, idDirection, len(args)+1)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	// One extra row is fetched to find out whether there is another page after this one.
+	args = append(args, filters.limit()+1)
+	rows, err := m.DB.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, Metadata{}, err
+	}
+	defer rows.Close()
+
+	movies := []*Movie{}
+
+	for rows.Next() {
+		var movie Movie
+		err := rows.Scan(
+			&movie.ID,
+			&movie.CreatedAt,
+			&movie.Title,
+			&movie.Year,
+			&movie.Runtime,
+			pq.Array(&movie.Genres),
+			&movie.Status,
+			&movie.AverageRating,
+			&movie.RatingCount,
+			&movie.Version,
+			&movie.Headline,
+		)
+		if err != nil {
+			return nil, Metadata{}, err
+		}
+
+		movies = append(movies, &movie)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, Metadata{}, err
+	}
+
+	more := len(movies) > filters.limit()
+	if more {
+		movies = movies[:filters.limit()]
+	}
+
+	if cursor.Backward {
+		slices.Reverse(movies)
+	}
+
+	metadata := Metadata{PageSize: filters.PageSize}
+
+	if len(movies) == 0 {
+		return movies, metadata, nil
+	}
+
+	first, last := movies[0], movies[len(movies)-1]
+
+	if more || cursor.Backward {
+		metadata.NextCursor = Cursor{Sort: filters.Sort, Value: movieSortValue(last, column), ID: last.ID}.encode()
+	}
+
+	if (cursor.Backward && more) || (!cursor.Backward && !cursor.first()) {
+		metadata.PrevCursor = Cursor{Sort: filters.Sort, Value: movieSortValue(first, column), ID: first.ID, Backward: true}.encode()
+	}
+
+	return movies, metadata, nil
+}
+
+// movieSortColumn returns the column the movies are ordered by for the requested sort.
+func movieSortColumn(filters Filters) string {
+	column := filters.sortColumn()
+	if c, ok := movieSortColumns[column]; ok {
+		return c
+	}
+
+	return column
+}
+
+// movieSortValue returns the value of a sort column for the given movie, in a form
+// that can be stored in a cursor and compared against the column again.
+func movieSortValue(movie *Movie, column string) string {
+	switch column {
+	case "title":
+		return movie.Title
+	case "year":
+		return strconv.FormatInt(int64(movie.Year), 10)
+	case "runtime":
+		return strconv.FormatInt(int64(movie.Runtime), 10)
+	case "average_rating":
+		return strconv.FormatFloat(movie.AverageRating, 'f', -1, 64)
+	default:
+		return movie.ID.String()
+	}
+}
+
+// Facets which can be counted across the movies matching a set of filters.
+const (
+	FacetGenres  = "genres"
+	FacetDecade  = "decade"
+	FacetRuntime = "runtime"
+)
+
+var MovieFacets = []string{FacetGenres, FacetDecade, FacetRuntime}
+
+// FacetCount is the number of matching movies which share a value of a facet.
+type FacetCount struct {
+	Value string `json:"value"`
+	Count int    `json:"count"`
+}
+
+// movieFacetQueries select the value and count of every bucket of each facet. Runtimes
+// are grouped into half-hour buckets, with everything under 90 minutes and from 150
+// minutes upwards sharing a bucket.
+var movieFacetQueries = map[string]string{
+	FacetGenres: `
+	SELECT genre, count(*)
+	FROM movies CROSS JOIN LATERAL unnest(movies.genres) AS genre
+	%s
+	GROUP BY genre
+	ORDER BY count(*) DESC, genre ASC`,
+	FacetDecade: `
+	SELECT (year / 10 * 10)::text || 's', count(*)
+	FROM movies
+	%s
+	GROUP BY year / 10
+	ORDER BY year / 10 ASC`,
+	FacetRuntime: `
+	SELECT bucket, count(*)
+	FROM (
+		SELECT CASE
+			WHEN runtime < 90 THEN '0-89'
+			WHEN runtime < 120 THEN '90-119'
+			WHEN runtime < 150 THEN '120-149'
+			ELSE '150+'
+		END AS bucket, runtime
+		FROM movies
+		%s
+	) AS buckets
+	GROUP BY bucket
+	ORDER BY min(runtime) ASC`,
+}
+
+// GetFacets counts the movies matching the filters by every value of the requested
+// facets, using the same WHERE clause as GetAll.
+func (m MovieModel) GetFacets(mf MovieFilters, facets []string) (map[string][]FacetCount, error) {
+	where, args := mf.where()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	counts := make(map[string][]FacetCount, len(facets))
+
+	for _, facet := range facets {
+		rows, err := m.DB.QueryContext(ctx, fmt.Sprintf(movieFacetQueries[facet], where), args...)
+		if err != nil {
+			return nil, err
+		}
+
+		counts[facet] = []FacetCount{}
+
+		for rows.Next() {
+			var count FacetCount
+
+			err := rows.Scan(&count.Value, &count.Count)
+			if err != nil {
+				rows.Close()
+				return nil, err
+			}
+
+			counts[facet] = append(counts[facet], count)
+		}
+
+		err = rows.Err()
+		rows.Close()
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return counts, nil
+}
+
+// Suggestion is a lightweight movie match returned while the user is still typing.
+type Suggestion struct {
+	ID    uuid.UUID `json:"id"`
+	Title string    `json:"title"`
+	Year  int32     `json:"year"`
+}
+
+func ValidateSuggestQuery(v *validator.Validator, q string, limit int) {
+	v.Check(q != "", "q", "must be provided")
+	v.Check(len(q) <= 100, "q", "must not be more than 100 bytes long")
+	v.Check(limit > 0, "limit", "must be greater than zero")
+	v.Check(limit <= 20, "limit", "must be a maximum of 20")
+}
+
+// likeEscaper escapes the characters which have a special meaning in LIKE patterns.
+var likeEscaper = strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`)
+
+// Suggest returns the movies whose titles best match a partially typed, and possibly
+// misspelled, query. Titles containing the query come first, starting with those which
+// begin with it, followed by the titles most similar to it, and then by the movies
+// matched through their alternate titles. Both kinds of match are served by the trigram
+// indexes on title and alternate_titles.
+func (m MovieModel) Suggest(q string, limit int) ([]*Suggestion, error) {
+	query := `
+		SELECT id, title, year
+		FROM movies
+		WHERE (title ILIKE '%' || $2 || '%' OR $1 <% title
+			OR alternate_titles ILIKE '%' || $2 || '%' OR $1 <% alternate_titles)
+		AND deleted_at IS NULL
+		ORDER BY title ILIKE $2 || '%' DESC, title ILIKE '%' || $2 || '%' DESC, word_similarity($1, title) DESC,
+			word_similarity($1, alternate_titles) DESC, title ASC
+		LIMIT $3`
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	rows, err := m.DB.QueryContext(ctx, query, q, likeEscaper.Replace(q), limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	suggestions := []*Suggestion{}
+
+	for rows.Next() {
+		var suggestion Suggestion
+
+		err := rows.Scan(&suggestion.ID, &suggestion.Title, &suggestion.Year)
+		if err != nil {
+			return nil, err
+		}
+
+		suggestions = append(suggestions, &suggestion)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return suggestions, nil
+}
+
+// FindDuplicates returns up to five existing movies which are probably the same film as
+// the given one. A movie is a candidate when its title is the same once case and
+// punctuation are ignored, or is very similar, and its year and runtime are close.
+func (m MovieModel) FindDuplicates(movie *Movie) ([]*Movie, error) {
+	query := `
+		SELECT id, created_at, updated_at, title, year, runtime, genres, status, average_rating, rating_count, version
+		FROM movies
+		WHERE (regexp_replace(lower(title), '[^[:alnum:]]+', '', 'g') = regexp_replace(lower($1), '[^[:alnum:]]+', '', 'g')
+			OR similarity(title, $1) >= 0.6)
+		AND abs(year - $2::integer) <= 1
+		AND abs(runtime - $3::integer) <= greatest(10, $3::integer / 10)
+		AND id <> $4
+		AND deleted_at IS NULL
+		ORDER BY similarity(title, $1) DESC, abs(year - $2::integer) ASC, id ASC
+		LIMIT 5`
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	rows, err := m.DB.QueryContext(ctx, query, movie.Title, movie.Year, movie.Runtime, movie.ID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	candidates := []*Movie{}
+
+	for rows.Next() {
+		var candidate Movie
+
+		err := rows.Scan(
+			&candidate.ID,
+			&candidate.CreatedAt,
+			&candidate.UpdatedAt,
+			&candidate.Title,
+			&candidate.Year,
+			&candidate.Runtime,
+			pq.Array(&candidate.Genres),
+			&candidate.Status,
+			&candidate.AverageRating,
+			&candidate.RatingCount,
+			&candidate.Version,
+		)
+		if err != nil {
+			return nil, err
+		}
+
+		candidates = append(candidates, &candidate)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return candidates, nil
+}
+
+// Merge folds the movie with the given id into another one, which survives it. The
+// reviews, list entries and credits of the merged movie are moved over, except where
+// the surviving movie already has an equivalent one, the merged movie is removed, and
+// a redirect from its id to the surviving movie is left behind. Any redirects which
+// pointed at the merged movie are updated to point at the surviving one, and a new
+// version of it is recorded, attributed to the given user.
+func (m MovieModel) Merge(id, intoID, userID uuid.UUID) error {
+	if id == uuid.Nil || intoID == uuid.Nil {
+		return ErrRecordNotFound
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	tx, err := m.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	// Relations are locked before the movies, in the same order as RelationModel.Insert
+	// takes its locks, so that the two can't deadlock.
+	_, err = tx.ExecContext(ctx, `LOCK TABLE movie_relations IN SHARE ROW EXCLUSIVE MODE`)
+	if err != nil {
+		return err
+	}
+
+	// Lock both movies, in a consistent order so that concurrent merges can't deadlock.
+	query := `
+		SELECT count(*)
+		FROM (
+			SELECT id
+			FROM movies
+			WHERE id IN ($1, $2) AND deleted_at IS NULL
+			ORDER BY id
+			FOR UPDATE
+		) AS locked`
+
+	var count int
+
+	err = tx.QueryRowContext(ctx, query, id, intoID).Scan(&count)
+	if err != nil {
+		return err
+	}
+
+	if count != 2 {
+		return ErrRecordNotFound
+	}
+
+	// Lock the lists and collections which the merged movie is on, as their items may
+	// be renumbered.
+	for _, stmt := range []string{
+		`SELECT id
+		FROM lists
+		WHERE id IN (SELECT list_id FROM list_items WHERE movie_id = $1)
+		ORDER BY id
+		FOR UPDATE`,
+		`SELECT id
+		FROM collections
+		WHERE id IN (SELECT collection_id FROM collection_items WHERE movie_id = $1)
+		ORDER BY id
+		FOR UPDATE`,
+	} {
+		_, err = tx.ExecContext(ctx, stmt, id)
+		if err != nil {
+			return err
+		}
+	}
+
+	queries := []string{
+		// A user who reviewed both movies keeps their review of the surviving one.
+		`DELETE FROM reviews
+		WHERE movie_id = $1 AND user_id IN (SELECT user_id FROM reviews WHERE movie_id = $2)`,
+		`UPDATE reviews SET movie_id = $2 WHERE movie_id = $1`,
+		// A list which has both movies keeps the surviving one at its position, and the
+		// items after the merged one move up to close the gap.
+		`WITH removed AS (
+			DELETE FROM list_items
+			WHERE movie_id = $1 AND list_id IN (SELECT list_id FROM list_items WHERE movie_id = $2)
+			RETURNING list_id, position
+		)
+		UPDATE list_items
+		SET position = list_items.position - 1
+		FROM removed
+		WHERE list_items.list_id = removed.list_id AND list_items.position > removed.position`,
+		`UPDATE list_items SET movie_id = $2 WHERE movie_id = $1`,
+		// So does a collection.
+		`WITH removed AS (
+			DELETE FROM collection_items
+			WHERE movie_id = $1 AND collection_id IN (SELECT collection_id FROM collection_items WHERE movie_id = $2)
+			RETURNING collection_id, position
+		)
+		UPDATE collection_items
+		SET position = collection_items.position - 1
+		FROM removed
+		WHERE collection_items.collection_id = removed.collection_id AND collection_items.position > removed.position`,
+		`UPDATE collection_items SET movie_id = $2 WHERE movie_id = $1`,
+		// Credits which the surviving movie already has are deleted along with the
+		// merged movie.
+		`UPDATE movie_credits
+		SET movie_id = $2
+		WHERE movie_id = $1 AND NOT EXISTS (
+			SELECT 1
+			FROM movie_credits AS existing
+			WHERE existing.movie_id = $2 AND existing.person_id = movie_credits.person_id AND existing.role = movie_credits.role
+		)`,
+		// External ids are moved for the sources which the surviving movie has no id from.
+		`UPDATE movie_external_ids
+		SET movie_id = $2
+		WHERE movie_id = $1 AND source NOT IN (SELECT source FROM movie_external_ids WHERE movie_id = $2)`,
+		// So are images, for the kinds which the surviving movie has no image of.
+		`UPDATE movie_images
+		SET movie_id = $2
+		WHERE movie_id = $1 AND kind NOT IN (SELECT kind FROM movie_images WHERE movie_id = $2)`,
+		// So are alternate titles, apart from those the surviving movie already has, and
+		// the original title if it has one.
+		`UPDATE movie_titles
+		SET movie_id = $2
+		WHERE movie_id = $1 AND NOT EXISTS (
+			SELECT 1
+			FROM movie_titles AS existing
+			WHERE existing.movie_id = $2
+			AND ((existing.language, existing.region, existing.type, existing.title) =
+				(movie_titles.language, movie_titles.region, movie_titles.type, movie_titles.title)
+				OR (existing.type = 'original' AND movie_titles.type = 'original'))
+		)`,
+		// Releases are moved unless the surviving movie has the same one, and
+		// certifications for the countries which it has no certification in.
+		`UPDATE movie_releases
+		SET movie_id = $2
+		WHERE movie_id = $1 AND NOT EXISTS (
+			SELECT 1
+			FROM movie_releases AS existing
+			WHERE existing.movie_id = $2
+			AND (existing.country, existing.type, existing.date) = (movie_releases.country, movie_releases.type, movie_releases.date)
+		)`,
+		`UPDATE movie_certifications
+		SET movie_id = $2
+		WHERE movie_id = $1 AND country NOT IN (SELECT country FROM movie_certifications WHERE movie_id = $2)`,
+		// Relations between the two movies are dropped, and the others are moved unless
+		// the surviving movie already has them.
+		`DELETE FROM movie_relations
+		WHERE (movie_id, related_movie_id) IN (($1, $2), ($2, $1))`,
+		`UPDATE movie_relations
+		SET movie_id = $2
+		WHERE movie_id = $1 AND NOT EXISTS (
+			SELECT 1
+			FROM movie_relations AS existing
+			WHERE existing.movie_id = $2
+			AND (existing.related_movie_id, existing.type) = (movie_relations.related_movie_id, movie_relations.type)
+		)`,
+		`UPDATE movie_relations
+		SET related_movie_id = $2
+		WHERE related_movie_id = $1 AND NOT EXISTS (
+			SELECT 1
+			FROM movie_relations AS existing
+			WHERE existing.related_movie_id = $2
+			AND (existing.movie_id, existing.type) = (movie_relations.movie_id, movie_relations.type)
+		)`,
+		`UPDATE movie_redirects SET movie_id = $2 WHERE movie_id = $1`,
+	}
+
+	for _, stmt := range queries {
+		_, err = tx.ExecContext(ctx, stmt, id, intoID)
+		if err != nil {
+			return err
+		}
+	}
+
+	// Moving the relations of the merged movie can close a loop through the surviving one.
+	cycle, err := reachesMovie(ctx, tx, intoID, intoID)
+	if err != nil {
+		return err
+	}
+
+	if cycle {
+		return ErrRelationCycle
+	}
+
+	query = `
+		INSERT INTO movie_redirects (id, movie_id, user_id)
+		VALUES ($1, $2, $3)`
+
+	_, err = tx.ExecContext(ctx, query, id, intoID, uuid.NullUUID{UUID: userID, Valid: userID != uuid.Nil})
+	if err != nil {
+		return err
+	}
+
+	_, err = tx.ExecContext(ctx, `DELETE FROM movies WHERE id = $1`, id)
+	if err != nil {
+		return err
+	}
+
+	err = updateMovieRating(ctx, tx, intoID)
+	if err != nil {
+		return err
+	}
+
+	err = updateAlternateTitles(ctx, tx, intoID)
+	if err != nil {
+		return err
+	}
+
+	err = touchMovie(ctx, tx, intoID, userID)
+	if err != nil {
+		return err
+	}
+
+	err = deriveMovieYear(ctx, tx, intoID, userID)
+	if err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// GetRedirect returns the id of the movie which the movie with the given id was merged
+// into.
+func (m MovieModel) GetRedirect(id uuid.UUID) (uuid.UUID, error) {
+	if id == uuid.Nil {
+		return uuid.Nil, ErrRecordNotFound
+	}
+
+	query := `
+		SELECT movie_id
+		FROM movie_redirects
+		WHERE id = $1`
+
+	var movieID uuid.UUID
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	err := m.DB.QueryRowContext(ctx, query, id).Scan(&movieID)
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return uuid.Nil, ErrRecordNotFound
+		default:
+			return uuid.Nil, err
+		}
+	}
+
+	return movieID, nil
+}
+
+// Restore takes a movie back out of the trash, and records it as a new version
+// attributed to the given user, so that its validators differ from the ones it had
+// before it was deleted.
+func (m MovieModel) Restore(id, userID uuid.UUID) error {
+	if id == uuid.Nil {
+		return ErrRecordNotFound
+	}
+
+	query := `
+		UPDATE movies
+		SET deleted_at = NULL, version = version + 1, updated_at = NOW()
+		WHERE id = $1 AND deleted_at IS NOT NULL
+		RETURNING id, title, year, runtime, genres, status, version`
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	tx, err := m.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	rowsAffected, err := reviseMovies(ctx, tx, query, []any{id}, userID)
+	if err != nil {
+		return err
+	}
+
+	if rowsAffected == 0 {
+		return ErrRecordNotFound
+	}
+
+	return tx.Commit()
+}
+
+// Purge permanently deletes the movies which were moved to the trash before the given
+// time, together with their images, which it returns so that their files can be
+// deleted, and returns how many movies were deleted. The movies are locked first, so
+// that none of them can be restored halfway through.
+func (m MovieModel) Purge(before time.Time) ([]*MovieImage, int64, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	tx, err := m.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer tx.Rollback()
+
+	var ids []uuid.UUID
+
+	query := `
+		SELECT id
+		FROM movies
+		WHERE deleted_at < $1
+		ORDER BY id
+		FOR UPDATE`
+
+	rows, err := tx.QueryContext(ctx, query, before)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var id uuid.UUID
+
+		err := rows.Scan(&id)
+		if err != nil {
+			return nil, 0, err
+		}
+
+		ids = append(ids, id)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, 0, err
+	}
+
+	rows.Close()
+
+	if len(ids) == 0 {
+		return []*MovieImage{}, 0, nil
+	}
+
+	query = `
+		DELETE FROM movie_images
+		WHERE movie_id = ANY($1::uuid[])
+		RETURNING movie_id, kind, created_at, width, height, keys`
+
+	rows, err = tx.QueryContext(ctx, query, uuidArray(ids))
+	if err != nil {
+		return nil, 0, err
+	}
+	defer rows.Close()
+
+	images := []*MovieImage{}
+
+	for rows.Next() {
+		image, err := scanMovieImage(rows)
+		if err != nil {
+			return nil, 0, err
+		}
+
+		images = append(images, image)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, 0, err
+	}
+
+	rows.Close()
+
+	result, err := tx.ExecContext(ctx, `DELETE FROM movies WHERE id = ANY($1::uuid[])`, uuidArray(ids))
+	if err != nil {
+		return nil, 0, err
+	}
+
+	count, err := result.RowsAffected()
+	if err != nil {
+		return nil, 0, err
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		return nil, 0, err
+	}
+
+	return images, count, nil
+}
+
+// GetAllDeleted returns the movies currently in the trash.
+func (m MovieModel) GetAllDeleted(filters Filters) ([]*Movie, Metadata, error) {
+	query := fmt.Sprintf(`
+	SELECT count(*) OVER(), id, created_at, title, year, runtime, genres, status, average_rating, rating_count, version, deleted_at
+	FROM movies
+	WHERE deleted_at IS NOT NULL
+	ORDER BY %s %s, id ASC
+	LIMIT $1 OFFSET $2`, filters.sortColumn(), filters.sortDirection())
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	rows, err := m.DB.QueryContext(ctx, query, filters.limit(), filters.offset())
+	if err != nil {
+		return nil, Metadata{}, err
+	}
+	defer rows.Close()
+
+	totalRecords := 0
+	movies := []*Movie{}
+
+	for rows.Next() {
+		var movie Movie
+		err := rows.Scan(
+			&totalRecords,
+			&movie.ID,
+			&movie.CreatedAt,
+			&movie.Title,
+			&movie.Year,
+			&movie.Runtime,
+			pq.Array(&movie.Genres),
+			&movie.Status,
+			&movie.AverageRating,
+			&movie.RatingCount,
+			&movie.Version,
+			&movie.DeletedAt,
+		)
+		if err != nil {
+			return nil, Metadata{}, err
+		}
+
+		movies = append(movies, &movie)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, Metadata{}, err
+	}
+
+	metadata := calculateMetadata(totalRecords, filters.Page, filters.PageSize)
+
+	return movies, metadata, nil
+}
+
+// GetUpcoming returns the movies which are yet to be released, sorted by the date they
+// are expected on: that of their next release, or else the end of their year. The
+// expected_release sort is the only one supported.
+func (m MovieModel) GetUpcoming(filters Filters) ([]*Movie, Metadata, error) {
+	query := fmt.Sprintf(`
+	SELECT count(*) OVER(), id, created_at, title, year, runtime, genres, status, average_rating, rating_count, version, next_release
+	FROM (
+		SELECT *, (
+			SELECT min(date) FROM movie_releases WHERE movie_id = movies.id AND date >= CURRENT_DATE
+		) AS next_release
+		FROM movies
+		WHERE status = ANY($1) AND deleted_at IS NULL
+	) AS upcoming
+	ORDER BY COALESCE(next_release, make_date(year, 12, 31)) %s, id ASC
+	LIMIT $2 OFFSET $3`, filters.sortDirection())
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	rows, err := m.DB.QueryContext(ctx, query, pq.Array(UpcomingMovieStatuses), filters.limit(), filters.offset())
+	if err != nil {
+		return nil, Metadata{}, err
+	}
+	defer rows.Close()
+
+	totalRecords := 0
+	movies := []*Movie{}
+
+	for rows.Next() {
+		var (
+			movie       Movie
+			nextRelease sql.NullTime
+		)
+
+		err := rows.Scan(
+			&totalRecords,
+			&movie.ID,
+			&movie.CreatedAt,
+			&movie.Title,
+			&movie.Year,
+			&movie.Runtime,
+			pq.Array(&movie.Genres),
+			&movie.Status,
+			&movie.AverageRating,
+			&movie.RatingCount,
+			&movie.Version,
+			&nextRelease,
+		)
+		if err != nil {
+			return nil, Metadata{}, err
+		}
+
+		if nextRelease.Valid {
+			movie.NextRelease = &Date{Time: nextRelease.Time}
+		}
+
+		movies = append(movies, &movie)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, Metadata{}, err
+	}
+
+	metadata := calculateMetadata(totalRecords, filters.Page, filters.PageSize)
+
+	return movies, metadata, nil
+}
+
+// Export calls fn with every movie which matches the filters, in the requested order.
+// The movies are read through a server-side cursor one batch at a time, so memory use
+// stays flat however large the catalogue is. Export stops at the first error returned
+// by fn.
+func (m MovieModel) Export(mf MovieFilters, filters Filters, fn func(*Movie) error) error {
+	where, args := mf.where()
+
+	query := fmt.Sprintf(`
+	DECLARE movies_export NO SCROLL CURSOR FOR
+	SELECT id, created_at, title, year, runtime, genres, status, average_rating, rating_count, version
+	FROM movies
+	%s
+	%s`, where, movieOrderBy(filters))
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Minute)
+	defer cancel()
+
+	tx, err := m.DB.BeginTx(ctx, &sql.TxOptions{ReadOnly: true})
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	_, err = tx.ExecContext(ctx, query, args...)
+	if err != nil {
+		return err
+	}
+
+	for {
+		fetched, err := fetchMovies(ctx, tx, "FETCH FORWARD 500 FROM movies_export", fn)
+		if err != nil {
+			return err
+		}
+
+		if fetched == 0 {
+			break
+		}
+	}
+
+	return tx.Commit()
+}
+
+// fetchMovies runs a FETCH against an open cursor and calls fn with each movie it
+// returns. It reports how many movies were fetched.
+func fetchMovies(ctx context.Context, tx *sql.Tx, query string, fn func(*Movie) error) (int, error) {
+	rows, err := tx.QueryContext(ctx, query)
+	if err != nil {
+		return 0, err
+	}
+	defer rows.Close()
+
+	fetched := 0
+
+	for rows.Next() {
+		var movie Movie
+		err := rows.Scan(
+			&movie.ID,
+			&movie.CreatedAt,
+			&movie.Title,
+			&movie.Year,
+			&movie.Runtime,
+			pq.Array(&movie.Genres),
+			&movie.Status,
+			&movie.AverageRating,
+			&movie.RatingCount,
+			&movie.Version,
+		)
+		if err != nil {
+			return 0, err
+		}
+
+		fetched++
+
+		err = fn(&movie)
+		if err != nil {
+			return 0, err
+		}
+	}
+
+	return fetched, rows.Err()
+}
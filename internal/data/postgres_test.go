@@ -4,6 +4,7 @@ package data
 
 import (
 	"database/sql"
+	"errors"
 	"fmt"
 	"log"
 	"os"
@@ -132,9 +133,13 @@ func TestPostgresDBRepoInsertMovie(t *testing.T) {
 		Year:    2001,
 		Runtime: 178,
 		Genres:  []string{"Action", "Adventure", "Drama", "Fantasy"},
+		Status:  MovieStatusReleased,
+		ExternalIDs: map[string]string{
+			ExternalSourceIMDb: "tt0120737",
+		},
 	}
 
-	err := testRepository.MovieModel.Insert(testMovie)
+	err := testRepository.MovieModel.Insert(testMovie, uuid.Nil)
 	if err != nil {
 		t.Errorf("insert movie returned error: %s", err)
 	}
@@ -157,16 +162,37 @@ func TestPostgresDBRepoGetMovie(t *testing.T) {
 	}
 }
 
+func TestPostgresDBRepoGetMovieByExternalID(t *testing.T) {
+	movie, err := testRepository.MovieModel.GetByExternalID(ExternalSourceIMDb, "tt0120737")
+	if err != nil {
+		t.Fatalf("cannot get movie by external id: %s", err)
+	}
+
+	if movie.ID != movieID {
+		t.Errorf("expected movie %s but got %s", movieID, movie.ID)
+	}
+
+	if movie.ExternalIDs[ExternalSourceIMDb] != "tt0120737" {
+		t.Errorf("expected the IMDb id to be loaded but got %v", movie.ExternalIDs)
+	}
+
+	_, err = testRepository.MovieModel.GetByExternalID(ExternalSourceTMDb, "120")
+	if !errors.Is(err, ErrRecordNotFound) {
+		t.Errorf("expected ErrRecordNotFound but got %v", err)
+	}
+}
+
 func TestPostgresDBRepoUpdateMovie(t *testing.T) {
 	movie := &Movie{
 		ID:      movieID,
 		Title:   "The Lord of the Rings: The Two Towers",
 		Genres:  []string{"Action", "Adventure", "Drama", "Fantasy", "Mystery"},
 		Year:    2002,
+		Status:  MovieStatusReleased,
 		Version: 1,
 	}
 
-	err := testRepository.MovieModel.Update(movie)
+	err := testRepository.MovieModel.Update(movie, uuid.Nil)
 	if err != nil {
 		t.Errorf("cannot update movie: %s", err)
 	}
@@ -196,7 +222,7 @@ func TestPostgresDBRepoGetAll(t *testing.T) {
 		Sort:         "id",
 		SortSafelist: []string{"id", "title", "year", "runtime", "-id", "-title", "-year", "-runtime"},
 	}
-	movies, _, err := testRepository.MovieModel.GetAll("Lord Of The Rings", []string{"Action"}, filters)
+	movies, _, err := testRepository.MovieModel.GetAll(MovieFilters{Title: "Lord Of The Rings", Genres: []string{"Action"}}, filters)
 	if err != nil {
 		t.Error("cannot get all movies")
 	}
@@ -204,10 +230,89 @@ func TestPostgresDBRepoGetAll(t *testing.T) {
 	if len(movies) != 1 {
 		t.Errorf("expected 1 movie but got %d", len(movies))
 	}
+
+	tests := []struct {
+		name     string
+		filters  MovieFilters
+		expected int
+	}{
+		{"year range", MovieFilters{YearMin: 2000, YearMax: 2002}, 1},
+		{"runtime range", MovieFilters{RuntimeMin: 90}, 0},
+		{"any genre", MovieFilters{GenresAny: []string{"Comedy", "Fantasy"}}, 1},
+		{"excluded genre", MovieFilters{ExcludeGenres: []string{"Drama"}}, 0},
+		{"misspelled title", MovieFilters{Title: "Two Towrs"}, 1},
+	}
+
+	for _, tt := range tests {
+		movies, _, err := testRepository.MovieModel.GetAll(tt.filters, filters)
+		if err != nil {
+			t.Errorf("%s: cannot get all movies: %s", tt.name, err)
+		}
+
+		if len(movies) != tt.expected {
+			t.Errorf("%s: expected %d movies but got %d", tt.name, tt.expected, len(movies))
+		}
+	}
+}
+
+func TestPostgresDBRepoGetFacets(t *testing.T) {
+	facets, err := testRepository.MovieModel.GetFacets(MovieFilters{Genres: []string{"Fantasy"}}, MovieFacets)
+	if err != nil {
+		t.Fatalf("cannot get facets: %s", err)
+	}
+
+	if len(facets[FacetGenres]) != 5 {
+		t.Errorf("expected 5 genres but got %d", len(facets[FacetGenres]))
+	}
+
+	if len(facets[FacetDecade]) != 1 || facets[FacetDecade][0].Value != "2000s" {
+		t.Errorf("expected a single 2000s decade but got %v", facets[FacetDecade])
+	}
+
+	if len(facets[FacetRuntime]) != 1 || facets[FacetRuntime][0].Value != "0-89" {
+		t.Errorf("expected a single 0-89 runtime bucket but got %v", facets[FacetRuntime])
+	}
+}
+
+func TestPostgresDBRepoSuggestMovies(t *testing.T) {
+	for _, q := range []string{"tower", "towrs"} {
+		suggestions, err := testRepository.MovieModel.Suggest(q, 10)
+		if err != nil {
+			t.Errorf("cannot suggest movies: %s", err)
+		}
+
+		if len(suggestions) != 1 {
+			t.Errorf("expected 1 suggestion for %q but got %d", q, len(suggestions))
+		}
+	}
+}
+
+func TestPostgresDBRepoFindDuplicateMovies(t *testing.T) {
+	tests := []struct {
+		name     string
+		movie    *Movie
+		expected int
+	}{
+		{"same title", &Movie{Title: "the lord of the rings - the two towers", Year: 2002, Runtime: 0}, 1},
+		{"close year and runtime", &Movie{Title: "The Lord of the Rings: The Two Towers", Year: 2003, Runtime: 5}, 1},
+		{"distant year", &Movie{Title: "The Lord of the Rings: The Two Towers", Year: 1978, Runtime: 0}, 0},
+		{"different title", &Movie{Title: "The Return of the King", Year: 2002, Runtime: 0}, 0},
+	}
+
+	for _, tt := range tests {
+		duplicates, err := testRepository.MovieModel.FindDuplicates(tt.movie)
+		if err != nil {
+			t.Errorf("%s: cannot find duplicates: %s", tt.name, err)
+		}
+
+		if len(duplicates) != tt.expected {
+			t.Errorf("%s: expected %d duplicates but got %d", tt.name, tt.expected, len(duplicates))
+		}
+	}
 }
 
 func TestPostgresDBRepoDeleteMovie(t *testing.T) {
-	err := testRepository.MovieModel.Delete(movieID)
+	err := testRepository.MovieModel.Delete(movieID, 0)
 	if err != nil {
 		t.Errorf("cannot delete movie: %s", err)
 	}
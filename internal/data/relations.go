@@ -0,0 +1,230 @@
+package data
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/lib/pq"
+	"github.com/petrostrak/gomdb/internal/validator"
+)
+
+const (
+	RelationSequelOf  = "sequel_of"
+	RelationRemakeOf  = "remake_of"
+	RelationSpinOffOf = "spin_off_of"
+	RelationBasedOn   = "based_on"
+
+	RelationOutgoing = "outgoing"
+	RelationIncoming = "incoming"
+)
+
+var (
+	ErrDuplicateRelation = errors.New("duplicate relation")
+	ErrRelationCycle     = errors.New("relation cycle")
+
+	// RelationTypes holds every type a relation between two movies can have.
+	RelationTypes = []string{RelationSequelOf, RelationRemakeOf, RelationSpinOffOf, RelationBasedOn}
+)
+
+// Relation is a directed relationship from a movie to another one which it derives
+// from, such as a sequel to the movie it follows. Relations never form a cycle,
+// whatever their types.
+type Relation struct {
+	MovieID        uuid.UUID `json:"movie_id"`
+	RelatedMovieID uuid.UUID `json:"related_movie_id"`
+	Type           string    `json:"type"`
+	CreatedAt      time.Time `json:"created_at"`
+}
+
+// RelatedMovie is a movie reached by following relations from another movie, either
+// in their direction (outgoing) or against it (incoming), in Depth steps. Type is the
+// type of the last relation followed.
+type RelatedMovie struct {
+	Type      string `json:"type"`
+	Direction string `json:"direction"`
+	Depth     int    `json:"depth"`
+	Movie     *Movie `json:"movie"`
+}
+
+func ValidateRelation(v *validator.Validator, relation *Relation) {
+	v.Check(relation.RelatedMovieID != uuid.Nil, "related_movie_id", "must be provided")
+	v.Check(relation.RelatedMovieID != relation.MovieID, "related_movie_id", "must not be the movie itself")
+
+	v.Check(relation.Type != "", "type", "must be provided")
+	v.Check(validator.In(relation.Type, RelationTypes...), "type", "must be one of sequel_of, remake_of, spin_off_of or based_on")
+}
+
+type RelationModel struct {
+	DB *sql.DB
+}
+
+// Insert relates a movie to another one, unless the other movie already leads back to
+// it through the existing relations.
+func (m RelationModel) Insert(relation *Relation) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	tx, err := m.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	// Inserts are serialised, as two concurrent relations could otherwise form a cycle
+	// which neither of them sees on its own.
+	_, err = tx.ExecContext(ctx, `LOCK TABLE movie_relations IN SHARE ROW EXCLUSIVE MODE`)
+	if err != nil {
+		return err
+	}
+
+	cycle, err := reachesMovie(ctx, tx, relation.RelatedMovieID, relation.MovieID)
+	if err != nil {
+		return err
+	}
+
+	if cycle {
+		return ErrRelationCycle
+	}
+
+	query := `
+		INSERT INTO movie_relations (movie_id, related_movie_id, type)
+		VALUES ($1, $2, $3)
+		RETURNING created_at`
+
+	err = tx.QueryRowContext(ctx, query, relation.MovieID, relation.RelatedMovieID, relation.Type).Scan(&relation.CreatedAt)
+	if err != nil {
+		switch {
+		case err.Error() == `pq: duplicate key value violates unique constraint "movie_relations_pkey"`:
+			return ErrDuplicateRelation
+		default:
+			return err
+		}
+	}
+
+	return tx.Commit()
+}
+
+// GetRelated walks the relations of a movie in both directions, up to the given depth,
+// and returns the movies it reaches, each at the shortest depth it was reached at. An
+// empty relationType follows relations of every type.
+func (m RelationModel) GetRelated(movieID uuid.UUID, relationType string, depth int) ([]*RelatedMovie, error) {
+	query := `
+		WITH RECURSIVE related AS (
+			SELECT related_movie_id AS id, type, 'outgoing'::text AS direction, 1 AS depth
+			FROM movie_relations
+			WHERE movie_id = $1 AND (type = $2 OR $2 = '')
+			UNION ALL
+			SELECT movie_id, type, 'incoming'::text, 1
+			FROM movie_relations
+			WHERE related_movie_id = $1 AND (type = $2 OR $2 = '')
+			UNION ALL
+			SELECT CASE related.direction WHEN 'outgoing' THEN movie_relations.related_movie_id ELSE movie_relations.movie_id END,
+				movie_relations.type, related.direction, related.depth + 1
+			FROM related
+			INNER JOIN movie_relations
+			ON related.id = CASE related.direction WHEN 'outgoing' THEN movie_relations.movie_id ELSE movie_relations.related_movie_id END
+			WHERE related.depth < $3 AND (movie_relations.type = $2 OR $2 = '')
+		)
+		SELECT shortest.type, shortest.direction, shortest.depth,
+			movies.id, movies.title, movies.year, movies.runtime, movies.genres, movies.status, movies.version
+		FROM (
+			SELECT DISTINCT ON (id, direction) id, type, direction, depth
+			FROM related
+			ORDER BY id, direction, depth, type
+		) AS shortest
+		INNER JOIN movies ON movies.id = shortest.id
+		WHERE movies.deleted_at IS NULL
+		ORDER BY shortest.depth, shortest.direction DESC, movies.year, movies.title, movies.id`
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	rows, err := m.DB.QueryContext(ctx, query, movieID, relationType, depth)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	related := []*RelatedMovie{}
+
+	for rows.Next() {
+		var r RelatedMovie
+		var movie Movie
+
+		err := rows.Scan(
+			&r.Type,
+			&r.Direction,
+			&r.Depth,
+			&movie.ID,
+			&movie.Title,
+			&movie.Year,
+			&movie.Runtime,
+			pq.Array(&movie.Genres),
+			&movie.Status,
+			&movie.Version,
+		)
+		if err != nil {
+			return nil, err
+		}
+
+		r.Movie = &movie
+		related = append(related, &r)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return related, nil
+}
+
+func (m RelationModel) Delete(movieID, relatedMovieID uuid.UUID, relationType string) error {
+	query := `
+		DELETE FROM movie_relations
+		WHERE movie_id = $1 AND related_movie_id = $2 AND type = $3`
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	result, err := m.DB.ExecContext(ctx, query, movieID, relatedMovieID, relationType)
+	if err != nil {
+		return err
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if rowsAffected == 0 {
+		return ErrRecordNotFound
+	}
+
+	return nil
+}
+
+// reachesMovie reports whether the movie toID can be reached from the movie fromID by
+// following one or more relations in their direction.
+func reachesMovie(ctx context.Context, tx *sql.Tx, fromID, toID uuid.UUID) (bool, error) {
+	query := `
+		WITH RECURSIVE reachable AS (
+			SELECT related_movie_id AS id FROM movie_relations WHERE movie_id = $1
+			UNION
+			SELECT movie_relations.related_movie_id
+			FROM movie_relations
+			INNER JOIN reachable ON movie_relations.movie_id = reachable.id
+		)
+		SELECT EXISTS (SELECT 1 FROM reachable WHERE id = $2)`
+
+	var reached bool
+
+	err := tx.QueryRowContext(ctx, query, fromID, toID).Scan(&reached)
+	if err != nil {
+		return false, err
+	}
+
+	return reached, nil
+}
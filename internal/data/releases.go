@@ -0,0 +1,339 @@
+package data
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"regexp"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/lib/pq"
+	"github.com/petrostrak/gomdb/internal/validator"
+)
+
+const (
+	ReleaseTypePremiere   = "premiere"
+	ReleaseTypeTheatrical = "theatrical"
+	ReleaseTypeDigital    = "digital"
+	ReleaseTypePhysical   = "physical"
+)
+
+var (
+	ErrDuplicateRelease = errors.New("duplicate release")
+
+	// ReleaseTypes holds every type a release can have.
+	ReleaseTypes = []string{ReleaseTypePremiere, ReleaseTypeTheatrical, ReleaseTypeDigital, ReleaseTypePhysical}
+
+	// CountryRX matches ISO 3166 alpha-2 country codes.
+	CountryRX = regexp.MustCompile(`^[A-Z]{2}$`)
+)
+
+// Release is an event at which a movie was, or will be, released in a country. The
+// year of a movie is the year of its earliest release.
+type Release struct {
+	ID      int64     `json:"id"`
+	MovieID uuid.UUID `json:"-"`
+	Country string    `json:"country"`
+	Date    Date      `json:"date"`
+	Type    string    `json:"type"`
+}
+
+// Certification is the age rating, such as PG-13 or 15, that a movie was given in a
+// country.
+type Certification struct {
+	MovieID       uuid.UUID `json:"-"`
+	Country       string    `json:"country"`
+	Certification string    `json:"certification"`
+}
+
+func ValidateRelease(v *validator.Validator, release *Release) {
+	ValidateCountry(v, release.Country)
+
+	v.Check(!release.Date.IsZero(), "date", "must be provided")
+	v.Check(release.Date.Year() >= 1888, "date", "must be later than 1888")
+
+	v.Check(release.Type != "", "type", "must be provided")
+	v.Check(validator.In(release.Type, ReleaseTypes...), "type", "must be one of premiere, theatrical, digital or physical")
+}
+
+func ValidateCertification(v *validator.Validator, certification *Certification) {
+	ValidateCountry(v, certification.Country)
+
+	v.Check(certification.Certification != "", "certification", "must be provided")
+	v.Check(len(certification.Certification) <= 20, "certification", "must not be more than 20 bytes long")
+}
+
+func ValidateCountry(v *validator.Validator, country string) {
+	v.Check(country != "", "country", "must be provided")
+	v.Check(validator.Matches(country, CountryRX), "country", "must be an uppercase ISO 3166 country code")
+}
+
+type ReleaseModel struct {
+	DB *sql.DB
+}
+
+// Insert adds a release to a movie. If it is now the movie's earliest release, the
+// year of the movie is changed to match, and the change is recorded as a revision
+// attributed to the given user.
+func (m ReleaseModel) Insert(release *Release, userID uuid.UUID) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	tx, err := m.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	query := `
+		INSERT INTO movie_releases (movie_id, country, date, type)
+		VALUES ($1, $2, $3, $4)
+		RETURNING id`
+
+	args := []any{release.MovieID, release.Country, release.Date.Time, release.Type}
+
+	err = tx.QueryRowContext(ctx, query, args...).Scan(&release.ID)
+	if err != nil {
+		switch {
+		case err.Error() == `pq: duplicate key value violates unique constraint "movie_releases_movie_id_country_type_date_key"`:
+			return ErrDuplicateRelease
+		default:
+			return err
+		}
+	}
+
+	err = deriveMovieYear(ctx, tx, release.MovieID, userID)
+	if err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+func (m ReleaseModel) GetAllForMovie(movieID uuid.UUID) ([]*Release, error) {
+	releases, err := m.GetAllForMovies([]uuid.UUID{movieID})
+	if err != nil {
+		return nil, err
+	}
+
+	if releases[movieID] == nil {
+		return []*Release{}, nil
+	}
+
+	return releases[movieID], nil
+}
+
+func (m ReleaseModel) GetAllForMovies(movieIDs []uuid.UUID) (map[uuid.UUID][]*Release, error) {
+	query := `
+		SELECT id, movie_id, country, date, type
+		FROM movie_releases
+		WHERE movie_id = ANY($1::uuid[])
+		ORDER BY date, country, id`
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	rows, err := m.DB.QueryContext(ctx, query, uuidArray(movieIDs))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	releases := make(map[uuid.UUID][]*Release)
+
+	for rows.Next() {
+		var release Release
+
+		err := rows.Scan(
+			&release.ID,
+			&release.MovieID,
+			&release.Country,
+			&release.Date.Time,
+			&release.Type,
+		)
+		if err != nil {
+			return nil, err
+		}
+
+		releases[release.MovieID] = append(releases[release.MovieID], &release)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return releases, nil
+}
+
+// Delete removes a release from a movie. If it was the movie's earliest release, the
+// year of the movie is changed to the year of the earliest one left, if any.
+func (m ReleaseModel) Delete(movieID uuid.UUID, id int64, userID uuid.UUID) error {
+	if id < 1 {
+		return ErrRecordNotFound
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	tx, err := m.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	query := `
+		DELETE FROM movie_releases
+		WHERE id = $1 AND movie_id = $2`
+
+	result, err := tx.ExecContext(ctx, query, id, movieID)
+	if err != nil {
+		return err
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if rowsAffected == 0 {
+		return ErrRecordNotFound
+	}
+
+	err = deriveMovieYear(ctx, tx, movieID, userID)
+	if err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// deriveMovieYear sets the year of a movie to the year of its earliest release. When
+// the year changes, the new version of the movie is recorded as a revision attributed
+// to the given user. A movie without releases keeps its year. ErrFutureReleaseYear is
+// returned if a released movie would get a year in the future. It is called inside
+// the transaction which changed the releases.
+func deriveMovieYear(ctx context.Context, tx *sql.Tx, movieID, userID uuid.UUID) error {
+	query := `
+		UPDATE movies
+		SET year = earliest.year, version = version + 1, updated_at = NOW()
+		FROM (SELECT date_part('year', min(date))::integer AS year FROM movie_releases WHERE movie_id = $1) AS earliest
+		WHERE movies.id = $1 AND movies.deleted_at IS NULL AND movies.year <> earliest.year
+		RETURNING movies.id, movies.title, movies.year, movies.runtime, movies.genres, movies.status, movies.version`
+
+	var movie Movie
+
+	err := tx.QueryRowContext(ctx, query, movieID).Scan(
+		&movie.ID,
+		&movie.Title,
+		&movie.Year,
+		&movie.Runtime,
+		pq.Array(&movie.Genres),
+		&movie.Status,
+		&movie.Version,
+	)
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return nil
+		case err.Error() == `pq: new row for relation "movies" violates check constraint "movies_year_check"`:
+			return ErrFutureReleaseYear
+		default:
+			return err
+		}
+	}
+
+	return insertRevision(ctx, tx, &movie, userID)
+}
+
+type CertificationModel struct {
+	DB *sql.DB
+}
+
+// Set gives a movie a certification in a country, replacing the one it had there.
+func (m CertificationModel) Set(certification *Certification) error {
+	query := `
+		INSERT INTO movie_certifications (movie_id, country, certification)
+		VALUES ($1, $2, $3)
+		ON CONFLICT (movie_id, country) DO UPDATE SET certification = EXCLUDED.certification`
+
+	args := []any{certification.MovieID, certification.Country, certification.Certification}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	_, err := m.DB.ExecContext(ctx, query, args...)
+	return err
+}
+
+func (m CertificationModel) GetAllForMovie(movieID uuid.UUID) ([]*Certification, error) {
+	certifications, err := m.GetAllForMovies([]uuid.UUID{movieID})
+	if err != nil {
+		return nil, err
+	}
+
+	if certifications[movieID] == nil {
+		return []*Certification{}, nil
+	}
+
+	return certifications[movieID], nil
+}
+
+func (m CertificationModel) GetAllForMovies(movieIDs []uuid.UUID) (map[uuid.UUID][]*Certification, error) {
+	query := `
+		SELECT movie_id, country, certification
+		FROM movie_certifications
+		WHERE movie_id = ANY($1::uuid[])
+		ORDER BY country`
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	rows, err := m.DB.QueryContext(ctx, query, uuidArray(movieIDs))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	certifications := make(map[uuid.UUID][]*Certification)
+
+	for rows.Next() {
+		var certification Certification
+
+		err := rows.Scan(&certification.MovieID, &certification.Country, &certification.Certification)
+		if err != nil {
+			return nil, err
+		}
+
+		certifications[certification.MovieID] = append(certifications[certification.MovieID], &certification)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return certifications, nil
+}
+
+func (m CertificationModel) Delete(movieID uuid.UUID, country string) error {
+	query := `
+		DELETE FROM movie_certifications
+		WHERE movie_id = $1 AND country = $2`
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	result, err := m.DB.ExecContext(ctx, query, movieID, country)
+	if err != nil {
+		return err
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if rowsAffected == 0 {
+		return ErrRecordNotFound
+	}
+
+	return nil
+}
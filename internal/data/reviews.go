@@ -0,0 +1,333 @@
+package data
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/petrostrak/gomdb/internal/validator"
+)
+
+var ErrDuplicateReview = errors.New("duplicate review")
+
+// Review holds a user's rating, on a scale from 1 to 10, and an optional written
+// review of a movie. Each user can only review a movie once.
+type Review struct {
+	ID        int64     `json:"id"`
+	CreatedAt time.Time `json:"created_at"`
+	MovieID   uuid.UUID `json:"movie_id"`
+	UserID    uuid.UUID `json:"user_id"`
+	Rating    int32     `json:"rating"`
+	Body      string    `json:"body,omitempty"`
+	Version   int32     `json:"version"`
+}
+
+func ValidateReview(v *validator.Validator, review *Review) {
+	v.Check(review.Rating != 0, "rating", "must be provided")
+	v.Check(review.Rating >= 1 && review.Rating <= 10, "rating", "must be between 1 and 10")
+
+	v.Check(len(review.Body) <= 20_000, "body", "must not be more than 20000 bytes long")
+}
+
+type ReviewModel struct {
+	DB *sql.DB
+}
+
+// Insert adds a new review and refreshes the aggregate rating of the movie in the
+// same transaction.
+func (m ReviewModel) Insert(review *Review) error {
+	query := `
+		INSERT INTO reviews (movie_id, user_id, rating, body)
+		VALUES ($1, $2, $3, $4)
+		RETURNING id, created_at, version`
+
+	args := []any{review.MovieID, review.UserID, review.Rating, review.Body}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	tx, err := m.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	err = lockRatedMovie(ctx, tx, review.MovieID)
+	if err != nil {
+		return err
+	}
+
+	err = tx.QueryRowContext(ctx, query, args...).Scan(&review.ID, &review.CreatedAt, &review.Version)
+	if err != nil {
+		switch {
+		case err.Error() == `pq: duplicate key value violates unique constraint "reviews_movie_id_user_id_key"`:
+			return ErrDuplicateReview
+		default:
+			return err
+		}
+	}
+
+	err = updateMovieRating(ctx, tx, review.MovieID)
+	if err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// GetForUser retrieves the review a specific user left on a specific movie.
+func (m ReviewModel) GetForUser(movieID, userID uuid.UUID) (*Review, error) {
+	query := `
+		SELECT id, created_at, movie_id, user_id, rating, body, version
+		FROM reviews
+		WHERE movie_id = $1 AND user_id = $2`
+
+	var review Review
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	err := m.DB.QueryRowContext(ctx, query, movieID, userID).Scan(
+		&review.ID,
+		&review.CreatedAt,
+		&review.MovieID,
+		&review.UserID,
+		&review.Rating,
+		&review.Body,
+		&review.Version,
+	)
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return nil, ErrRecordNotFound
+		default:
+			return nil, err
+		}
+	}
+
+	return &review, nil
+}
+
+func (m ReviewModel) Update(review *Review) error {
+	query := `
+		UPDATE reviews
+		SET rating = $1, body = $2, version = version + 1
+		WHERE id = $3 AND version = $4
+		RETURNING version`
+
+	args := []any{review.Rating, review.Body, review.ID, review.Version}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	tx, err := m.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	err = lockRatedMovie(ctx, tx, review.MovieID)
+	if err != nil {
+		return err
+	}
+
+	err = tx.QueryRowContext(ctx, query, args...).Scan(&review.Version)
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return ErrEditConflict
+		default:
+			return err
+		}
+	}
+
+	err = updateMovieRating(ctx, tx, review.MovieID)
+	if err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// Delete removes the review a specific user left on a specific movie.
+func (m ReviewModel) Delete(movieID, userID uuid.UUID) error {
+	query := `
+		DELETE FROM reviews
+		WHERE movie_id = $1 AND user_id = $2`
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	tx, err := m.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	err = lockRatedMovie(ctx, tx, movieID)
+	if err != nil {
+		return err
+	}
+
+	result, err := tx.ExecContext(ctx, query, movieID, userID)
+	if err != nil {
+		return err
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if rowsAffected == 0 {
+		return ErrRecordNotFound
+	}
+
+	err = updateMovieRating(ctx, tx, movieID)
+	if err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+func (m ReviewModel) GetAllForMovie(movieID uuid.UUID, filters Filters) ([]*Review, Metadata, error) {
+	query := fmt.Sprintf(`
+	SELECT count(*) OVER(), id, created_at, movie_id, user_id, rating, body, version
+	FROM reviews
+	WHERE movie_id = $1
+	ORDER BY %s %s, id ASC
+	LIMIT $2 OFFSET $3`, filters.sortColumn(), filters.sortDirection())
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	rows, err := m.DB.QueryContext(ctx, query, movieID, filters.limit(), filters.offset())
+	if err != nil {
+		return nil, Metadata{}, err
+	}
+	defer rows.Close()
+
+	totalRecords := 0
+	reviews := []*Review{}
+
+	for rows.Next() {
+		var review Review
+		err := rows.Scan(
+			&totalRecords,
+			&review.ID,
+			&review.CreatedAt,
+			&review.MovieID,
+			&review.UserID,
+			&review.Rating,
+			&review.Body,
+			&review.Version,
+		)
+		if err != nil {
+			return nil, Metadata{}, err
+		}
+
+		reviews = append(reviews, &review)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, Metadata{}, err
+	}
+
+	metadata := calculateMetadata(totalRecords, filters.Page, filters.PageSize)
+
+	return reviews, metadata, nil
+}
+
+// GetLatestForMovies returns up to limit of the most recent reviews of several movies at
+// once, keyed by movie id. Movies without any reviews are left out of the map.
+func (m ReviewModel) GetLatestForMovies(movieIDs []uuid.UUID, limit int) (map[uuid.UUID][]*Review, error) {
+	query := `
+		SELECT id, created_at, movie_id, user_id, rating, body, version
+		FROM (
+			SELECT *, row_number() OVER (PARTITION BY movie_id ORDER BY created_at DESC, id DESC) AS rank
+			FROM reviews
+			WHERE movie_id = ANY($1::uuid[])
+		) AS latest
+		WHERE rank <= $2
+		ORDER BY movie_id, rank`
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	rows, err := m.DB.QueryContext(ctx, query, uuidArray(movieIDs), limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	reviews := make(map[uuid.UUID][]*Review)
+
+	for rows.Next() {
+		var review Review
+		err := rows.Scan(
+			&review.ID,
+			&review.CreatedAt,
+			&review.MovieID,
+			&review.UserID,
+			&review.Rating,
+			&review.Body,
+			&review.Version,
+		)
+		if err != nil {
+			return nil, err
+		}
+
+		reviews[review.MovieID] = append(reviews[review.MovieID], &review)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return reviews, nil
+}
+
+// lockRatedMovie locks the movie a review is about, so that concurrent changes to its
+// reviews are serialised and each of them aggregates the ratings the others committed.
+// It returns ErrRecordNotFound if the movie doesn't exist or is in the trash.
+func lockRatedMovie(ctx context.Context, tx *sql.Tx, movieID uuid.UUID) error {
+	query := `
+		SELECT id
+		FROM movies
+		WHERE id = $1 AND deleted_at IS NULL
+		FOR UPDATE`
+
+	err := tx.QueryRowContext(ctx, query, movieID).Scan(&movieID)
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return ErrRecordNotFound
+		default:
+			return err
+		}
+	}
+
+	return nil
+}
+
+// updateMovieRating recalculates the average_rating and rating_count columns of a
+// movie from its reviews. It is called inside the transaction which modified them,
+// after that transaction has locked the movie.
+func updateMovieRating(ctx context.Context, tx *sql.Tx, movieID uuid.UUID) error {
+	query := `
+		UPDATE movies
+		SET average_rating = aggregate.average_rating, rating_count = aggregate.rating_count
+		FROM (
+			SELECT COALESCE(round(avg(rating), 2), 0) AS average_rating, count(*) AS rating_count
+			FROM reviews
+			WHERE movie_id = $1
+		) AS aggregate
+		WHERE movies.id = $1`
+
+	_, err := tx.ExecContext(ctx, query, movieID)
+	return err
+}
@@ -0,0 +1,232 @@
+package data
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"slices"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/lib/pq"
+)
+
+// Revision is a snapshot of a movie as it was at a specific version, together with
+// the user who made the change and the fields that changed since the previous version.
+type Revision struct {
+	MovieID   uuid.UUID     `json:"-"`
+	Version   int32         `json:"version"`
+	CreatedAt time.Time     `json:"created_at"`
+	UserID    uuid.NullUUID `json:"user_id"`
+	Title     string        `json:"title"`
+	Year      int32         `json:"year"`
+	Runtime   Runtime       `json:"runtime"`
+	Genres    []string      `json:"genres"`
+	Status    string        `json:"status"`
+	Changes   []FieldChange `json:"changes,omitempty"`
+}
+
+// FieldChange describes how the value of a single field changed between two versions.
+type FieldChange struct {
+	Field string `json:"field"`
+	From  any    `json:"from"`
+	To    any    `json:"to"`
+}
+
+// diffRevisions returns the fields whose values differ between two revisions.
+func diffRevisions(from, to *Revision) []FieldChange {
+	changes := []FieldChange{}
+
+	if from.Title != to.Title {
+		changes = append(changes, FieldChange{Field: "title", From: from.Title, To: to.Title})
+	}
+
+	if from.Year != to.Year {
+		changes = append(changes, FieldChange{Field: "year", From: from.Year, To: to.Year})
+	}
+
+	if from.Runtime != to.Runtime {
+		changes = append(changes, FieldChange{Field: "runtime", From: from.Runtime, To: to.Runtime})
+	}
+
+	if !slices.Equal(from.Genres, to.Genres) {
+		changes = append(changes, FieldChange{Field: "genres", From: from.Genres, To: to.Genres})
+	}
+
+	if from.Status != to.Status {
+		changes = append(changes, FieldChange{Field: "status", From: from.Status, To: to.Status})
+	}
+
+	return changes
+}
+
+type RevisionModel struct {
+	DB *sql.DB
+}
+
+// Get retrieves a specific version of a movie.
+func (m RevisionModel) Get(movieID uuid.UUID, version int32) (*Revision, error) {
+	query := `
+		SELECT movie_id, version, created_at, user_id, title, year, runtime, genres, status
+		FROM movie_revisions
+		WHERE movie_id = $1 AND version = $2`
+
+	var revision Revision
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	err := m.DB.QueryRowContext(ctx, query, movieID, version).Scan(
+		&revision.MovieID,
+		&revision.Version,
+		&revision.CreatedAt,
+		&revision.UserID,
+		&revision.Title,
+		&revision.Year,
+		&revision.Runtime,
+		pq.Array(&revision.Genres),
+		&revision.Status,
+	)
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return nil, ErrRecordNotFound
+		default:
+			return nil, err
+		}
+	}
+
+	return &revision, nil
+}
+
+// GetAllForMovie returns the revision history of a movie, with every revision carrying
+// the changes made since the version before it.
+func (m RevisionModel) GetAllForMovie(movieID uuid.UUID, filters Filters) ([]*Revision, Metadata, error) {
+	query := fmt.Sprintf(`
+	SELECT count(*) OVER(), movie_id, version, created_at, user_id, title, year, runtime, genres, status,
+		previous_version, previous_title, previous_year, previous_runtime, previous_genres, previous_status
+	FROM (
+		SELECT movie_id, version, created_at, user_id, title, year, runtime, genres, status,
+			lag(version) OVER w AS previous_version,
+			lag(title) OVER w AS previous_title,
+			lag(year) OVER w AS previous_year,
+			lag(runtime) OVER w AS previous_runtime,
+			lag(genres) OVER w AS previous_genres,
+			lag(status) OVER w AS previous_status
+		FROM movie_revisions
+		WHERE movie_id = $1
+		WINDOW w AS (ORDER BY version)
+	) AS revisions
+	ORDER BY %s %s
+	LIMIT $2 OFFSET $3`, filters.sortColumn(), filters.sortDirection())
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	rows, err := m.DB.QueryContext(ctx, query, movieID, filters.limit(), filters.offset())
+	if err != nil {
+		return nil, Metadata{}, err
+	}
+	defer rows.Close()
+
+	totalRecords := 0
+	revisions := []*Revision{}
+
+	for rows.Next() {
+		var revision Revision
+		var previous struct {
+			version sql.NullInt32
+			title   sql.NullString
+			year    sql.NullInt32
+			runtime sql.NullInt32
+			genres  []string
+			status  sql.NullString
+		}
+
+		err := rows.Scan(
+			&totalRecords,
+			&revision.MovieID,
+			&revision.Version,
+			&revision.CreatedAt,
+			&revision.UserID,
+			&revision.Title,
+			&revision.Year,
+			&revision.Runtime,
+			pq.Array(&revision.Genres),
+			&revision.Status,
+			&previous.version,
+			&previous.title,
+			&previous.year,
+			&previous.runtime,
+			pq.Array(&previous.genres),
+			&previous.status,
+		)
+		if err != nil {
+			return nil, Metadata{}, err
+		}
+
+		if previous.version.Valid {
+			revision.Changes = diffRevisions(&Revision{
+				Title:   previous.title.String,
+				Year:    previous.year.Int32,
+				Runtime: Runtime(previous.runtime.Int32),
+				Genres:  previous.genres,
+				Status:  previous.status.String,
+			}, &revision)
+		}
+
+		revisions = append(revisions, &revision)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, Metadata{}, err
+	}
+
+	metadata := calculateMetadata(totalRecords, filters.Page, filters.PageSize)
+
+	return revisions, metadata, nil
+}
+
+// insertRevision records the current state of a movie in the movie_revisions table. It
+// is called inside the transaction which inserted or updated the movie.
+func insertRevision(ctx context.Context, tx *sql.Tx, movie *Movie, userID uuid.UUID) error {
+	query := `
+		INSERT INTO movie_revisions (movie_id, version, user_id, title, year, runtime, genres, status)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8)`
+
+	args := []any{
+		movie.ID,
+		movie.Version,
+		uuid.NullUUID{UUID: userID, Valid: userID != uuid.Nil},
+		movie.Title,
+		movie.Year,
+		movie.Runtime,
+		pq.Array(movie.Genres),
+		movie.Status,
+	}
+
+	_, err := tx.ExecContext(ctx, query, args...)
+	return err
+}
+
+// reviseMovies runs an update of movies, which must bump their versions and return the
+// id, title, year, runtime, genres, status and version of each of them, and records a
+// revision of each updated movie attributed to the given user in the same statement.
+// It returns the number of movies updated.
+func reviseMovies(ctx context.Context, tx *sql.Tx, update string, args []any, userID uuid.UUID) (int64, error) {
+	query := fmt.Sprintf(`
+		WITH revised AS (%s)
+		INSERT INTO movie_revisions (movie_id, version, user_id, title, year, runtime, genres, status)
+		SELECT id, version, $%d::uuid, title, year, runtime, genres, status
+		FROM revised`, update, len(args)+1)
+
+	args = append(slices.Clip(args), uuid.NullUUID{UUID: userID, Valid: userID != uuid.Nil})
+
+	result, err := tx.ExecContext(ctx, query, args...)
+	if err != nil {
+		return 0, err
+	}
+
+	return result.RowsAffected()
+}
@@ -0,0 +1,36 @@
+package data
+
+import (
+	"testing"
+)
+
+func Test_diffRevisions(t *testing.T) {
+	from := &Revision{Title: "Alien", Year: 1979, Runtime: 117, Genres: []string{"horror", "sci-fi"}}
+
+	tests := []struct {
+		name     string
+		to       *Revision
+		expected []string
+	}{
+		{"unchanged", &Revision{Title: "Alien", Year: 1979, Runtime: 117, Genres: []string{"horror", "sci-fi"}}, []string{}},
+		{"title", &Revision{Title: "Aliens", Year: 1979, Runtime: 117, Genres: []string{"horror", "sci-fi"}}, []string{"title"}},
+		{"year and runtime", &Revision{Title: "Alien", Year: 1986, Runtime: 137, Genres: []string{"horror", "sci-fi"}}, []string{"year", "runtime"}},
+		{"genres order", &Revision{Title: "Alien", Year: 1979, Runtime: 117, Genres: []string{"sci-fi", "horror"}}, []string{"genres"}},
+		{"status", &Revision{Title: "Alien", Year: 1979, Runtime: 117, Genres: []string{"horror", "sci-fi"}, Status: "cancelled"}, []string{"status"}},
+	}
+
+	for _, tt := range tests {
+		changes := diffRevisions(from, tt.to)
+
+		if len(changes) != len(tt.expected) {
+			t.Errorf("%s: expected %d changes but got %d", tt.name, len(tt.expected), len(changes))
+			continue
+		}
+
+		for i, change := range changes {
+			if change.Field != tt.expected[i] {
+				t.Errorf("%s: expected change to %s but got %s", tt.name, tt.expected[i], change.Field)
+			}
+		}
+	}
+}
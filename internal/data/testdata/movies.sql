@@ -1,3 +1,5 @@
+CREATE EXTENSION IF NOT EXISTS pg_trgm;
+
 CREATE TABLE IF NOT EXISTS movies (
     id uuid DEFAULT gen_random_uuid() PRIMARY KEY,
     created_at timestamp(0) with time zone NOT NULL DEFAULT NOW(), 
@@ -5,13 +7,71 @@ CREATE TABLE IF NOT EXISTS movies (
     year integer NOT NULL,
     runtime integer NOT NULL,
     genres text[] NOT NULL,
-    version integer NOT NULL DEFAULT 1
+    version integer NOT NULL DEFAULT 1,
+    average_rating numeric(4, 2) NOT NULL DEFAULT 0,
+    rating_count integer NOT NULL DEFAULT 0,
+    deleted_at timestamp(0) with time zone,
+    updated_at timestamp(0) with time zone NOT NULL DEFAULT NOW(),
+    alternate_titles text NOT NULL DEFAULT '',
+    status text NOT NULL DEFAULT 'released',
+    search_vector tsvector GENERATED ALWAYS AS (
+        setweight(to_tsvector('english', title), 'A') || setweight(to_tsvector('simple', title), 'B') ||
+        setweight(to_tsvector('simple', alternate_titles), 'C')
+    ) STORED
 );
 
 ALTER TABLE movies ADD CONSTRAINT movies_runtime_check CHECK (runtime >= 0);
-ALTER TABLE movies ADD CONSTRAINT movies_year_check CHECK (year BETWEEN 1888 AND date_part('year', now())); 
+ALTER TABLE movies ADD CONSTRAINT movies_year_check CHECK (year >= 1888 AND (status <> 'released' OR year <= date_part('year', now())));
 ALTER TABLE movies ADD CONSTRAINT genres_length_check CHECK (array_length(genres, 1) BETWEEN 1 AND 5);
 
+CREATE TABLE IF NOT EXISTS movie_external_ids (
+    movie_id uuid NOT NULL REFERENCES movies ON DELETE CASCADE,
+    source text NOT NULL,
+    external_id text NOT NULL,
+    PRIMARY KEY (movie_id, source),
+    UNIQUE (source, external_id)
+);
+
+CREATE TABLE IF NOT EXISTS movie_releases (
+    id bigserial PRIMARY KEY,
+    movie_id uuid NOT NULL REFERENCES movies ON DELETE CASCADE,
+    country text NOT NULL,
+    date date NOT NULL,
+    type text NOT NULL,
+    UNIQUE (movie_id, country, type, date)
+);
+
+CREATE TABLE IF NOT EXISTS movie_revisions (
+    movie_id uuid NOT NULL REFERENCES movies ON DELETE CASCADE,
+    version integer NOT NULL,
+    created_at timestamp(0) with time zone NOT NULL DEFAULT NOW(),
+    user_id uuid,
+    title text NOT NULL,
+    year integer NOT NULL,
+    runtime integer NOT NULL,
+    genres text[] NOT NULL,
+    status text NOT NULL DEFAULT 'released',
+    PRIMARY KEY (movie_id, version)
+);
+
+CREATE TABLE IF NOT EXISTS people (
+    id uuid DEFAULT gen_random_uuid() PRIMARY KEY,
+    created_at timestamp(0) with time zone NOT NULL DEFAULT NOW(),
+    name text NOT NULL,
+    birth_year integer,
+    biography text NOT NULL DEFAULT '',
+    version integer NOT NULL DEFAULT 1
+);
+
+CREATE TABLE IF NOT EXISTS movie_credits (
+    id bigserial PRIMARY KEY,
+    movie_id uuid NOT NULL REFERENCES movies ON DELETE CASCADE,
+    person_id uuid NOT NULL REFERENCES people ON DELETE CASCADE,
+    role text NOT NULL,
+    character text NOT NULL DEFAULT '',
+    billing_order integer NOT NULL DEFAULT 0
+);
+
 CREATE EXTENSION citext;
 
 CREATE TABLE IF NOT EXISTS users (
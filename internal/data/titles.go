@@ -0,0 +1,224 @@
+package data
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"regexp"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/petrostrak/gomdb/internal/validator"
+)
+
+const (
+	TitleTypeOriginal   = "original"
+	TitleTypeWorking    = "working"
+	TitleTypeTranslated = "translated"
+)
+
+var (
+	ErrDuplicateTitle         = errors.New("duplicate title")
+	ErrDuplicateOriginalTitle = errors.New("duplicate original title")
+
+	// TitleTypes holds every type an alternate title can have.
+	TitleTypes = []string{TitleTypeOriginal, TitleTypeWorking, TitleTypeTranslated}
+
+	// LanguageRX matches ISO 639 language codes, and RegionRX ISO 3166 country codes or
+	// UN M.49 area codes, as used in language tags such as "fr" or "pt-BR".
+	LanguageRX = regexp.MustCompile(`^[a-z]{2,3}$`)
+	RegionRX   = regexp.MustCompile(`^([A-Z]{2}|[0-9]{3})$`)
+)
+
+// AlternateTitle is a title a movie is known by in a given language, and optionally
+// region, besides the one it is stored with. A movie has at most one original title.
+type AlternateTitle struct {
+	ID       int64     `json:"id"`
+	MovieID  uuid.UUID `json:"-"`
+	Title    string    `json:"title"`
+	Language string    `json:"language"`
+	Region   string    `json:"region,omitempty"`
+	Type     string    `json:"type"`
+}
+
+func ValidateAlternateTitle(v *validator.Validator, title *AlternateTitle) {
+	v.Check(title.Title != "", "title", "must be provided")
+	v.Check(len(title.Title) <= 500, "title", "must not be more than 500 bytes long")
+
+	v.Check(title.Language != "", "language", "must be provided")
+	v.Check(validator.Matches(title.Language, LanguageRX), "language", "must be a lowercase ISO 639 language code")
+
+	if title.Region != "" {
+		v.Check(validator.Matches(title.Region, RegionRX), "region", "must be an uppercase ISO 3166 country code")
+	}
+
+	v.Check(title.Type != "", "type", "must be provided")
+	v.Check(validator.In(title.Type, TitleTypes...), "type", "must be one of original, working or translated")
+}
+
+type AlternateTitleModel struct {
+	DB *sql.DB
+}
+
+// Insert adds an alternate title to a movie, and makes it searchable together with the
+// movie's other titles. A new version of the movie is recorded, attributed to the given
+// user, as the title it is shown with may have changed.
+func (m AlternateTitleModel) Insert(title *AlternateTitle, userID uuid.UUID) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	tx, err := m.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	err = touchMovie(ctx, tx, title.MovieID, userID)
+	if err != nil {
+		return err
+	}
+
+	query := `
+		INSERT INTO movie_titles (movie_id, title, language, region, type)
+		VALUES ($1, $2, $3, $4, $5)
+		RETURNING id`
+
+	args := []any{title.MovieID, title.Title, title.Language, title.Region, title.Type}
+
+	err = tx.QueryRowContext(ctx, query, args...).Scan(&title.ID)
+	if err != nil {
+		switch {
+		case err.Error() == `pq: duplicate key value violates unique constraint "movie_titles_movie_id_language_region_type_title_key"`:
+			return ErrDuplicateTitle
+		case err.Error() == `pq: duplicate key value violates unique constraint "movie_titles_original_idx"`:
+			return ErrDuplicateOriginalTitle
+		default:
+			return err
+		}
+	}
+
+	err = updateAlternateTitles(ctx, tx, title.MovieID)
+	if err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+func (m AlternateTitleModel) GetAllForMovie(movieID uuid.UUID) ([]*AlternateTitle, error) {
+	titles, err := m.GetAllForMovies([]uuid.UUID{movieID})
+	if err != nil {
+		return nil, err
+	}
+
+	if titles[movieID] == nil {
+		return []*AlternateTitle{}, nil
+	}
+
+	return titles[movieID], nil
+}
+
+func (m AlternateTitleModel) GetAllForMovies(movieIDs []uuid.UUID) (map[uuid.UUID][]*AlternateTitle, error) {
+	query := `
+		SELECT id, movie_id, title, language, region, type
+		FROM movie_titles
+		WHERE movie_id = ANY($1::uuid[])
+		ORDER BY array_position(ARRAY['original', 'translated', 'working'], type), language, region, id`
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	rows, err := m.DB.QueryContext(ctx, query, uuidArray(movieIDs))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	titles := make(map[uuid.UUID][]*AlternateTitle)
+
+	for rows.Next() {
+		var title AlternateTitle
+
+		err := rows.Scan(
+			&title.ID,
+			&title.MovieID,
+			&title.Title,
+			&title.Language,
+			&title.Region,
+			&title.Type,
+		)
+		if err != nil {
+			return nil, err
+		}
+
+		titles[title.MovieID] = append(titles[title.MovieID], &title)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return titles, nil
+}
+
+// Delete removes an alternate title from a movie, and records a new version of the movie
+// attributed to the given user.
+func (m AlternateTitleModel) Delete(movieID uuid.UUID, id int64, userID uuid.UUID) error {
+	if id < 1 {
+		return ErrRecordNotFound
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	tx, err := m.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	err = touchMovie(ctx, tx, movieID, userID)
+	if err != nil {
+		return err
+	}
+
+	query := `
+		DELETE FROM movie_titles
+		WHERE id = $1 AND movie_id = $2`
+
+	result, err := tx.ExecContext(ctx, query, id, movieID)
+	if err != nil {
+		return err
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if rowsAffected == 0 {
+		return ErrRecordNotFound
+	}
+
+	err = updateAlternateTitles(ctx, tx, movieID)
+	if err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// updateAlternateTitles copies the alternate titles of a movie onto the movie itself,
+// where they are indexed for search together with its title. It is called inside the
+// transaction which changed the alternate titles, which also touches the movie.
+func updateAlternateTitles(ctx context.Context, tx *sql.Tx, movieID uuid.UUID) error {
+	query := `
+		UPDATE movies
+		SET alternate_titles = COALESCE(
+			(SELECT string_agg(title, ' ' ORDER BY id) FROM movie_titles WHERE movie_id = $1),
+			''
+		)
+		WHERE id = $1`
+
+	_, err := tx.ExecContext(ctx, query, movieID)
+	return err
+}
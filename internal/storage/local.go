@@ -0,0 +1,117 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"io"
+	"io/fs"
+	"net/http"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+)
+
+// Local is a Storage which keeps files in a directory on the local filesystem. It is
+// also an http.Handler which serves them, for mounting at the path of its base URL.
+type Local struct {
+	dir     string
+	baseURL string
+}
+
+// NewLocal returns a Local storage which keeps files in dir, creating it if necessary,
+// and whose files are served from baseURL.
+func NewLocal(dir, baseURL string) (*Local, error) {
+	err := os.MkdirAll(dir, 0o755)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Local{dir: dir, baseURL: strings.TrimSuffix(baseURL, "/")}, nil
+}
+
+// path returns the location of the file saved under the key, making sure that it can't
+// be outside of the storage directory.
+func (s *Local) path(key string) (string, error) {
+	if key == "" || !fs.ValidPath(key) {
+		return "", ErrInvalidKey
+	}
+
+	return filepath.Join(s.dir, filepath.FromSlash(key)), nil
+}
+
+// Put writes the file to a temporary location first and then renames it, so that a
+// partly written file is never served.
+func (s *Local) Put(ctx context.Context, key string, r io.Reader, contentType string) error {
+	name, err := s.path(key)
+	if err != nil {
+		return err
+	}
+
+	err = os.MkdirAll(filepath.Dir(name), 0o755)
+	if err != nil {
+		return err
+	}
+
+	f, err := os.CreateTemp(filepath.Dir(name), ".upload-*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(f.Name())
+
+	_, err = io.Copy(f, r)
+	if err != nil {
+		f.Close()
+		return err
+	}
+
+	err = f.Close()
+	if err != nil {
+		return err
+	}
+
+	return os.Rename(f.Name(), name)
+}
+
+func (s *Local) Delete(ctx context.Context, key string) error {
+	name, err := s.path(key)
+	if err != nil {
+		return err
+	}
+
+	err = os.Remove(name)
+	if err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return err
+	}
+
+	return nil
+}
+
+func (s *Local) URL(key string) string {
+	return s.baseURL + "/" + key
+}
+
+// ServeHTTP serves the file saved under the key given by the request path, which must
+// already have the base URL stripped from it. Directories are never listed.
+func (s *Local) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	name, err := s.path(strings.TrimPrefix(path.Clean(r.URL.Path), "/"))
+	if err != nil || strings.HasSuffix(r.URL.Path, "/") {
+		http.NotFound(w, r)
+		return
+	}
+
+	f, err := os.Open(name)
+	if err != nil {
+		http.NotFound(w, r)
+		return
+	}
+	defer f.Close()
+
+	info, err := f.Stat()
+	if err != nil || info.IsDir() {
+		http.NotFound(w, r)
+		return
+	}
+
+	http.ServeContent(w, r, info.Name(), info.ModTime(), f)
+}
@@ -0,0 +1,64 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestLocal(t *testing.T) {
+	s, err := NewLocal(t.TempDir(), "/v1/images/")
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	ctx := context.Background()
+
+	err = s.Put(ctx, "movies/1/poster.jpg", strings.NewReader("poster"), "image/jpeg")
+	if err != nil {
+		t.Fatalf("cannot put file: %s", err)
+	}
+
+	if url := s.URL("movies/1/poster.jpg"); url != "/v1/images/movies/1/poster.jpg" {
+		t.Errorf("unexpected URL %q", url)
+	}
+
+	tests := []struct {
+		path   string
+		status int
+	}{
+		{"/movies/1/poster.jpg", http.StatusOK},
+		{"/movies/1/", http.StatusNotFound},
+		{"/movies/../../etc/passwd", http.StatusNotFound},
+		{"/movies/2/poster.jpg", http.StatusNotFound},
+	}
+
+	for _, tt := range tests {
+		rr := httptest.NewRecorder()
+		s.ServeHTTP(rr, httptest.NewRequest(http.MethodGet, tt.path, nil))
+
+		if rr.Code != tt.status {
+			t.Errorf("%s: expected status %d but got %d", tt.path, tt.status, rr.Code)
+		}
+	}
+
+	for _, key := range []string{"", "/movies/1/poster.jpg", "../poster.jpg"} {
+		err = s.Put(ctx, key, strings.NewReader("poster"), "image/jpeg")
+		if !errors.Is(err, ErrInvalidKey) {
+			t.Errorf("%q: expected ErrInvalidKey but got %v", key, err)
+		}
+	}
+
+	err = s.Delete(ctx, "movies/1/poster.jpg")
+	if err != nil {
+		t.Fatalf("cannot delete file: %s", err)
+	}
+
+	err = s.Delete(ctx, "movies/1/poster.jpg")
+	if err != nil {
+		t.Errorf("deleting a missing file returned an error: %s", err)
+	}
+}
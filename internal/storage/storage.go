@@ -0,0 +1,22 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"io"
+)
+
+var ErrInvalidKey = errors.New("invalid storage key")
+
+// Storage saves files under slash-separated keys, such as "movies/<id>/poster.jpg", and
+// tells clients where to download them from. Implementations must be safe for
+// concurrent use.
+type Storage interface {
+	// Put saves the contents of r under the key, replacing any file already there.
+	Put(ctx context.Context, key string, r io.Reader, contentType string) error
+	// Delete removes the file saved under the key. Deleting a missing file is not an
+	// error.
+	Delete(ctx context.Context, key string) error
+	// URL returns the address clients can download the file saved under the key from.
+	URL(key string) string
+}
@@ -0,0 +1,4 @@
+DROP INDEX IF EXISTS movies_average_rating_idx;
+ALTER TABLE movies DROP COLUMN IF EXISTS rating_count;
+ALTER TABLE movies DROP COLUMN IF EXISTS average_rating;
+DROP TABLE IF EXISTS reviews;
@@ -0,0 +1,25 @@
+CREATE TABLE IF NOT EXISTS lists (
+    id uuid DEFAULT gen_random_uuid() PRIMARY KEY,
+    created_at timestamp(0) with time zone NOT NULL DEFAULT NOW(),
+    user_id uuid NOT NULL REFERENCES users ON DELETE CASCADE,
+    name text NOT NULL,
+    description text NOT NULL DEFAULT '',
+    visibility text NOT NULL DEFAULT 'private',
+    watchlist bool NOT NULL DEFAULT false,
+    version integer NOT NULL DEFAULT 1
+);
+
+ALTER TABLE lists ADD CONSTRAINT lists_visibility_check CHECK (visibility IN ('public', 'private'));
+
+CREATE INDEX IF NOT EXISTS lists_user_id_idx ON lists (user_id);
+CREATE UNIQUE INDEX IF NOT EXISTS lists_user_id_watchlist_idx ON lists (user_id) WHERE watchlist;
+
+CREATE TABLE IF NOT EXISTS list_items (
+    list_id uuid NOT NULL REFERENCES lists ON DELETE CASCADE,
+    movie_id uuid NOT NULL REFERENCES movies ON DELETE CASCADE,
+    position integer NOT NULL,
+    added_at timestamp(0) with time zone NOT NULL DEFAULT NOW(),
+    PRIMARY KEY (list_id, movie_id)
+);
+
+CREATE INDEX IF NOT EXISTS list_items_list_id_position_idx ON list_items (list_id, position);
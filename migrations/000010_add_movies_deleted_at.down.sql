@@ -0,0 +1,6 @@
+DELETE FROM permissions WHERE code = 'movies:admin';
+
+DELETE FROM movies WHERE deleted_at IS NOT NULL;
+
+DROP INDEX IF EXISTS movies_deleted_at_idx;
+ALTER TABLE movies DROP COLUMN IF EXISTS deleted_at;
@@ -0,0 +1,16 @@
+CREATE TABLE IF NOT EXISTS movie_revisions (
+    movie_id uuid NOT NULL REFERENCES movies ON DELETE CASCADE,
+    version integer NOT NULL,
+    created_at timestamp(0) with time zone NOT NULL DEFAULT NOW(),
+    user_id uuid REFERENCES users ON DELETE SET NULL,
+    title text NOT NULL,
+    year integer NOT NULL,
+    runtime integer NOT NULL,
+    genres text[] NOT NULL,
+    PRIMARY KEY (movie_id, version)
+);
+
+-- Record the current state of the existing movies as the first known revision.
+INSERT INTO movie_revisions (movie_id, version, title, year, runtime, genres)
+SELECT id, version, title, year, runtime, genres
+FROM movies;
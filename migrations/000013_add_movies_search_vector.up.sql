@@ -0,0 +1,10 @@
+-- Titles are indexed twice: stemmed with the english configuration, which ranks higher,
+-- and verbatim with the simple configuration, so that both kinds of query can be
+-- answered from the same column.
+ALTER TABLE movies ADD COLUMN IF NOT EXISTS search_vector tsvector GENERATED ALWAYS AS (
+    setweight(to_tsvector('english', title), 'A') || setweight(to_tsvector('simple', title), 'B')
+) STORED;
+
+CREATE INDEX IF NOT EXISTS movies_search_vector_idx ON movies USING GIN (search_vector);
+
+DROP INDEX IF EXISTS movies_title_idx;
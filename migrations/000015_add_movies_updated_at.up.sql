@@ -0,0 +1,10 @@
+ALTER TABLE movies ADD COLUMN IF NOT EXISTS updated_at timestamp(0) with time zone;
+
+-- Existing movies were last updated when their latest revision was recorded.
+UPDATE movies SET updated_at = COALESCE(
+    (SELECT max(created_at) FROM movie_revisions WHERE movie_revisions.movie_id = movies.id),
+    created_at
+);
+
+ALTER TABLE movies ALTER COLUMN updated_at SET DEFAULT NOW();
+ALTER TABLE movies ALTER COLUMN updated_at SET NOT NULL;
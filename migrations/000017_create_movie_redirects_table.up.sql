@@ -0,0 +1,8 @@
+CREATE TABLE IF NOT EXISTS movie_redirects (
+    id uuid PRIMARY KEY,
+    movie_id uuid NOT NULL REFERENCES movies ON DELETE CASCADE,
+    created_at timestamp(0) with time zone NOT NULL DEFAULT NOW(),
+    user_id uuid REFERENCES users ON DELETE SET NULL
+);
+
+CREATE INDEX IF NOT EXISTS movie_redirects_movie_id_idx ON movie_redirects (movie_id);
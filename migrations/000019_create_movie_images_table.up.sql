@@ -0,0 +1,11 @@
+CREATE TABLE IF NOT EXISTS movie_images (
+    movie_id uuid NOT NULL REFERENCES movies ON DELETE CASCADE,
+    kind text NOT NULL,
+    created_at timestamp(0) with time zone NOT NULL DEFAULT NOW(),
+    width integer NOT NULL,
+    height integer NOT NULL,
+    keys jsonb NOT NULL,
+    PRIMARY KEY (movie_id, kind)
+);
+
+ALTER TABLE movie_images ADD CONSTRAINT movie_images_kind_check CHECK (kind IN ('poster', 'backdrop'));
@@ -0,0 +1,12 @@
+DROP INDEX IF EXISTS movies_alternate_titles_trgm_idx;
+
+ALTER TABLE movies DROP COLUMN IF EXISTS search_vector;
+ALTER TABLE movies ADD COLUMN search_vector tsvector GENERATED ALWAYS AS (
+    setweight(to_tsvector('english', title), 'A') || setweight(to_tsvector('simple', title), 'B')
+) STORED;
+
+CREATE INDEX IF NOT EXISTS movies_search_vector_idx ON movies USING GIN (search_vector);
+
+ALTER TABLE movies DROP COLUMN IF EXISTS alternate_titles;
+
+DROP TABLE IF EXISTS movie_titles;
@@ -0,0 +1,26 @@
+CREATE TABLE IF NOT EXISTS movie_titles (
+    id bigserial PRIMARY KEY,
+    movie_id uuid NOT NULL REFERENCES movies ON DELETE CASCADE,
+    title text NOT NULL,
+    language text NOT NULL,
+    region text NOT NULL DEFAULT '',
+    type text NOT NULL,
+    UNIQUE (movie_id, language, region, type, title)
+);
+
+ALTER TABLE movie_titles ADD CONSTRAINT movie_titles_type_check CHECK (type IN ('original', 'working', 'translated'));
+
+CREATE UNIQUE INDEX IF NOT EXISTS movie_titles_original_idx ON movie_titles (movie_id) WHERE type = 'original';
+
+-- The alternate titles of every movie are copied onto it, so that they can be indexed
+-- for search together with its title, with a lower weight.
+ALTER TABLE movies ADD COLUMN IF NOT EXISTS alternate_titles text NOT NULL DEFAULT '';
+
+ALTER TABLE movies DROP COLUMN IF EXISTS search_vector;
+ALTER TABLE movies ADD COLUMN search_vector tsvector GENERATED ALWAYS AS (
+    setweight(to_tsvector('english', title), 'A') || setweight(to_tsvector('simple', title), 'B') ||
+    setweight(to_tsvector('simple', alternate_titles), 'C')
+) STORED;
+
+CREATE INDEX IF NOT EXISTS movies_search_vector_idx ON movies USING GIN (search_vector);
+CREATE INDEX IF NOT EXISTS movies_alternate_titles_trgm_idx ON movies USING GIN (alternate_titles gin_trgm_ops);
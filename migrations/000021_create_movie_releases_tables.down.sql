@@ -0,0 +1,2 @@
+DROP TABLE IF EXISTS movie_certifications;
+DROP TABLE IF EXISTS movie_releases;
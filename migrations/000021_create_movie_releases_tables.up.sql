@@ -0,0 +1,19 @@
+CREATE TABLE IF NOT EXISTS movie_releases (
+    id bigserial PRIMARY KEY,
+    movie_id uuid NOT NULL REFERENCES movies ON DELETE CASCADE,
+    country text NOT NULL,
+    date date NOT NULL,
+    type text NOT NULL,
+    UNIQUE (movie_id, country, type, date)
+);
+
+ALTER TABLE movie_releases ADD CONSTRAINT movie_releases_type_check CHECK (type IN ('premiere', 'theatrical', 'digital', 'physical'));
+
+CREATE INDEX IF NOT EXISTS movie_releases_country_date_idx ON movie_releases (country, date);
+
+CREATE TABLE IF NOT EXISTS movie_certifications (
+    movie_id uuid NOT NULL REFERENCES movies ON DELETE CASCADE,
+    country text NOT NULL,
+    certification text NOT NULL,
+    PRIMARY KEY (movie_id, country)
+);
@@ -0,0 +1,11 @@
+ALTER TABLE movie_revisions DROP COLUMN IF EXISTS status;
+
+DROP INDEX IF EXISTS movies_status_idx;
+
+ALTER TABLE movies DROP CONSTRAINT IF EXISTS movies_year_check;
+-- Announced and in-production movies may be dated in the future, so the old check is
+-- only enforced for new rows rather than validated against the existing ones.
+ALTER TABLE movies ADD CONSTRAINT movies_year_check CHECK (year BETWEEN 1888 AND date_part('year', now())) NOT VALID;
+
+ALTER TABLE movies DROP CONSTRAINT IF EXISTS movies_status_check;
+ALTER TABLE movies DROP COLUMN IF EXISTS status;
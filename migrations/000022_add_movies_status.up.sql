@@ -0,0 +1,11 @@
+ALTER TABLE movies ADD COLUMN IF NOT EXISTS status text NOT NULL DEFAULT 'released';
+ALTER TABLE movies ADD CONSTRAINT movies_status_check CHECK (status IN ('announced', 'in_production', 'post_production', 'released', 'cancelled'));
+
+-- Only released movies are kept from having a year in the future, so that announced
+-- movies can be catalogued with the year they are expected in.
+ALTER TABLE movies DROP CONSTRAINT IF EXISTS movies_year_check;
+ALTER TABLE movies ADD CONSTRAINT movies_year_check CHECK (year >= 1888 AND (status <> 'released' OR year <= date_part('year', now())));
+
+CREATE INDEX IF NOT EXISTS movies_status_idx ON movies (status);
+
+ALTER TABLE movie_revisions ADD COLUMN IF NOT EXISTS status text NOT NULL DEFAULT 'released';
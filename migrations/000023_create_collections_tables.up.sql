@@ -0,0 +1,25 @@
+CREATE TABLE IF NOT EXISTS collections (
+    id uuid DEFAULT gen_random_uuid() PRIMARY KEY,
+    created_at timestamp(0) with time zone NOT NULL DEFAULT NOW(),
+    parent_id uuid REFERENCES collections ON DELETE SET NULL,
+    name text NOT NULL,
+    description text NOT NULL DEFAULT '',
+    position integer NOT NULL DEFAULT 0,
+    version integer NOT NULL DEFAULT 1
+);
+
+ALTER TABLE collections ADD CONSTRAINT collections_parent_id_check CHECK (parent_id <> id);
+ALTER TABLE collections ADD CONSTRAINT collections_position_check CHECK (position >= 0);
+
+CREATE INDEX IF NOT EXISTS collections_parent_id_idx ON collections (parent_id);
+
+CREATE TABLE IF NOT EXISTS collection_items (
+    collection_id uuid NOT NULL REFERENCES collections ON DELETE CASCADE,
+    movie_id uuid NOT NULL REFERENCES movies ON DELETE CASCADE,
+    position integer NOT NULL,
+    added_at timestamp(0) with time zone NOT NULL DEFAULT NOW(),
+    PRIMARY KEY (collection_id, movie_id)
+);
+
+CREATE INDEX IF NOT EXISTS collection_items_collection_id_position_idx ON collection_items (collection_id, position);
+CREATE INDEX IF NOT EXISTS collection_items_movie_id_idx ON collection_items (movie_id);
@@ -0,0 +1,12 @@
+CREATE TABLE IF NOT EXISTS movie_relations (
+    movie_id uuid NOT NULL REFERENCES movies ON DELETE CASCADE,
+    related_movie_id uuid NOT NULL REFERENCES movies ON DELETE CASCADE,
+    type text NOT NULL,
+    created_at timestamp(0) with time zone NOT NULL DEFAULT NOW(),
+    PRIMARY KEY (movie_id, related_movie_id, type)
+);
+
+ALTER TABLE movie_relations ADD CONSTRAINT movie_relations_related_movie_id_check CHECK (related_movie_id <> movie_id);
+ALTER TABLE movie_relations ADD CONSTRAINT movie_relations_type_check CHECK (type IN ('sequel_of', 'remake_of', 'spin_off_of', 'based_on'));
+
+CREATE INDEX IF NOT EXISTS movie_relations_related_movie_id_idx ON movie_relations (related_movie_id);
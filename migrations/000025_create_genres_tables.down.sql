@@ -0,0 +1,4 @@
+-- Movies keep the slugs of their genres, as the spellings they replaced are lost.
+DROP TABLE IF EXISTS genre_redirects;
+DROP TABLE IF EXISTS genre_names;
+DROP TABLE IF EXISTS genres;
@@ -0,0 +1,73 @@
+CREATE TABLE IF NOT EXISTS genres (
+    slug text PRIMARY KEY,
+    parent text REFERENCES genres ON UPDATE CASCADE ON DELETE SET NULL,
+    created_at timestamp(0) with time zone NOT NULL DEFAULT NOW(),
+    version integer NOT NULL DEFAULT 1
+);
+
+ALTER TABLE genres ADD CONSTRAINT genres_slug_check CHECK (slug ~ '^[a-z0-9]+(-[a-z0-9]+)*$');
+ALTER TABLE genres ADD CONSTRAINT genres_parent_check CHECK (parent <> slug);
+
+CREATE INDEX IF NOT EXISTS genres_parent_idx ON genres (parent);
+
+CREATE TABLE IF NOT EXISTS genre_names (
+    genre text NOT NULL REFERENCES genres ON UPDATE CASCADE ON DELETE CASCADE,
+    language text NOT NULL,
+    name text NOT NULL,
+    PRIMARY KEY (genre, language)
+);
+
+-- The slugs a genre had before being renamed or merged into another one, so that the
+-- revisions of movies which still use them can be reverted to.
+CREATE TABLE IF NOT EXISTS genre_redirects (
+    old_slug text PRIMARY KEY,
+    new_slug text NOT NULL REFERENCES genres ON UPDATE CASCADE ON DELETE CASCADE
+);
+
+-- Every genre in use is turned into a slug, so that spellings which only differ in case
+-- or punctuation, such as "Sci-Fi" and "sci fi", become the same genre. Its most common
+-- spelling is kept as its english name.
+CREATE TEMPORARY TABLE genre_spellings AS
+SELECT name, trim(BOTH '-' FROM regexp_replace(lower(name), '[^a-z0-9]+', '-', 'g')) AS slug, count(*) AS uses
+FROM (
+    SELECT unnest(genres) AS name FROM movies
+    UNION ALL
+    SELECT unnest(genres) AS name FROM movie_revisions
+) AS used
+GROUP BY name;
+
+-- Names made only of characters which can't appear in a slug, such as "Драма", are
+-- filed under a fallback genre rather than dropped, as a movie left without any genre
+-- would no longer pass validation.
+UPDATE genre_spellings SET slug = 'uncategorized' WHERE slug = '';
+
+INSERT INTO genres (slug)
+SELECT DISTINCT slug FROM genre_spellings;
+
+INSERT INTO genre_names (genre, language, name)
+SELECT DISTINCT ON (slug) slug, 'en', CASE WHEN slug = 'uncategorized' THEN 'Uncategorized' ELSE name END
+FROM genre_spellings
+ORDER BY slug, uses DESC, name;
+
+-- Movies, and their revisions so that they can still be reverted to, are rewritten to
+-- use the slugs, keeping their genres in order and dropping those which became
+-- duplicates.
+UPDATE movies SET genres = ARRAY(
+    SELECT genre_spellings.slug
+    FROM unnest(movies.genres) WITH ORDINALITY AS used(name, position)
+    INNER JOIN genre_spellings ON genre_spellings.name = used.name
+    GROUP BY genre_spellings.slug
+    ORDER BY min(used.position)
+)
+WHERE NOT genres <@ ARRAY(SELECT slug FROM genres);
+
+UPDATE movie_revisions SET genres = ARRAY(
+    SELECT genre_spellings.slug
+    FROM unnest(movie_revisions.genres) WITH ORDINALITY AS used(name, position)
+    INNER JOIN genre_spellings ON genre_spellings.name = used.name
+    GROUP BY genre_spellings.slug
+    ORDER BY min(used.position)
+)
+WHERE NOT genres <@ ARRAY(SELECT slug FROM genres);
+
+DROP TABLE genre_spellings;